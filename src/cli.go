@@ -0,0 +1,961 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"cybersaver/core"
+)
+
+const cliUsage = `usage: cybersaver <command> [arguments] [--json]
+
+commands:
+  profiles list
+  profiles create <name>
+  profiles delete <name>
+  profiles rename <name> <new-name>
+  profiles clone <name> <new-name>
+  profiles show <name>
+  profiles set <name> <description|character|lifepath|build|color|icon|mods> <value...>
+  load <profile> [--force]
+  recover [--force]   retry rolling back a failed switch; --force leaves the save folder as it is
+  import <profile>
+  import-archive <file.zip> [profile] [--merge]
+  verify-archive <file.zip>
+  saves list <profile> [--tag tag]... [--favorites]
+  saves tag <profile> <save> <tag>...
+  saves untag <profile> <save> <tag>...
+  saves star <profile> <save>
+  saves unstar <profile> <save>
+  saves label <profile> <save> [label]
+  copy-save <profile> <save> <target-profile> [--force]
+  diff <profile/save> <profile/save>
+  timeline <profile>
+  quests <profile> [--all]
+  search <query...>   e.g. search quest:"Phantom Liberty" level>40 type:manual
+  bulk <copy|move> <profile> <target-profile> [selection] [--force]
+  bulk <delete> <profile> [selection]
+  bulk <tag|untag> <profile> <tag>... [selection]
+  bulk export <profile> [-o file.zip] [--format zip|tar.zst] [selection]
+       selection: --saves a,b | [--type Auto|Manual] [--older-than days] [--tag tag]... [--skip-favorites]
+  export <profile> [-o file.zip|file.tar.zst] [--format zip|tar.zst] [--force]
+         [--saves a,b] [--snapshot id] [--no-screenshots] [--no-notes] [--with-snapshots]
+         [--sign] [--signer name]
+  prune <profile> [--dry-run]
+  prune policy <profile> [auto=N] [quick=N] [manual=N] [quick-per-quest=on|off] [on-exit=on|off]
+  signing-key
+  trusted-keys
+  trust-key <key-id> <name>
+  distrust-key <key-id>
+  trash list
+  trash restore <id> [profile]
+  trash purge [id]
+  snapshot list <profile>
+  snapshot create <profile> [name]
+  snapshot restore <profile> <id>
+  verify <profile> [--refresh]
+
+Without a command CyberSaver starts the tray app and web UI.
+`
+
+// errUsage makes runCLI print the usage text.
+var errUsage = errors.New("usage")
+
+type cliOptions struct {
+	args    []string
+	json    bool
+	force   bool
+	refresh bool
+	merge   bool
+	output  string
+	export  core.ExportOptions
+	tags    []string
+	starred bool
+	filter  core.SaveFilter
+	dryRun  bool
+	all     bool
+}
+
+func parseCLIArgs(args []string) (cliOptions, error) {
+	var o cliOptions
+	for i := 0; i < len(args); i++ {
+		switch a := args[i]; a {
+		case "--json":
+			o.json = true
+		case "--force", "-f":
+			o.force = true
+		case "--refresh":
+			o.refresh = true
+		case "--merge":
+			o.merge = true
+		case "--no-screenshots":
+			o.export.SkipScreenshots = true
+		case "--no-notes":
+			o.export.SkipNotes = true
+		case "--with-snapshots":
+			o.export.IncludeSnapshots = true
+		case "--sign":
+			o.export.Sign = true
+		case "--favorites":
+			o.starred = true
+		case "--all":
+			o.all = true
+		case "--dry-run", "-n":
+			o.dryRun = true
+		case "--skip-favorites":
+			o.filter.SkipFavorites = true
+		case "-o", "--output", "--format", "--saves", "--snapshot", "--signer", "--tag", "--type", "--older-than":
+			if i+1 >= len(args) {
+				return o, fmt.Errorf("%s needs a value", a)
+			}
+			i++
+			switch a {
+			case "--format":
+				o.export.Format = args[i]
+			case "--saves":
+				o.export.Saves = append(o.export.Saves, strings.Split(args[i], ",")...)
+			case "--snapshot":
+				o.export.Snapshot = args[i]
+			case "--signer":
+				o.export.Signer = args[i]
+				o.export.Sign = true
+			case "--tag":
+				o.tags = append(o.tags, args[i])
+			case "--type":
+				o.filter.Type = args[i]
+			case "--older-than":
+				days, err := strconv.Atoi(args[i])
+				if err != nil || days < 0 {
+					return o, fmt.Errorf("--older-than needs a number of days")
+				}
+				o.filter.OlderThanDays = days
+			default:
+				o.output = args[i]
+			}
+		case "--":
+			o.args = append(o.args, args[i+1:]...)
+			return o, nil
+		default:
+			if strings.HasPrefix(a, "-") {
+				return o, fmt.Errorf("unknown flag %s", a)
+			}
+			o.args = append(o.args, a)
+		}
+	}
+	return o, nil
+}
+
+// runCLI runs one command without the tray or web UI and returns the process
+// exit code. It uses the same server operations as the HTTP handlers.
+func runCLI(args []string) int {
+	attachConsole()
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		fmt.Fprint(os.Stdout, cliUsage)
+		return 0
+	}
+	o, err := parseCLIArgs(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "cybersaver: %v\n\n%s", err, cliUsage)
+		return 2
+	}
+	s, err := newServer(loadConfig())
+	if err == nil {
+		s.SetGameRunning(isGameRunning())
+		err = s.runCommand(os.Stdout, o)
+	}
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	default:
+		printCLIError(o, err)
+		return 1
+	}
+}
+
+func (s *server) runCommand(out io.Writer, o cliOptions) error {
+	arg := func(i int) string {
+		if i < len(o.args) {
+			return o.args[i]
+		}
+		return ""
+	}
+	need := func(n int) error {
+		if len(o.args) < n {
+			return errUsage
+		}
+		return nil
+	}
+	switch cmd := arg(0) + " " + arg(1); {
+	case cmd == "profiles list":
+		profiles := s.Profiles()
+		active := s.ActiveProfile()
+		return printResult(out, o, map[string]any{"profiles": profiles, "active": active}, func(w io.Writer) {
+			for _, p := range profiles {
+				mark := " "
+				if p.Name == active {
+					mark = "*"
+				}
+				fmt.Fprintf(w, "%s %s", mark, p.Name)
+				if p.CharacterName != "" {
+					fmt.Fprintf(w, " (%s)", p.CharacterName)
+				}
+				if p.Description != "" {
+					fmt.Fprintf(w, " - %s", firstLine(p.Description))
+				}
+				fmt.Fprintln(w)
+			}
+		})
+	case cmd == "profiles create":
+		if err := need(3); err != nil {
+			return err
+		}
+		name, err := s.CreateProfile(arg(2))
+		if err != nil {
+			return err
+		}
+		return printStatus(out, o, map[string]string{"status": "created", "profile": name}, "created profile "+name)
+	case cmd == "profiles delete":
+		if err := need(3); err != nil {
+			return err
+		}
+		item, err := s.DeleteProfile(arg(2))
+		if err != nil {
+			return err
+		}
+		return printStatus(out, o, map[string]any{"status": "deleted", "profile": item.Profile, "trash": item},
+			"moved profile "+item.Profile+" to the trash ("+item.ID+")")
+	case cmd == "profiles rename", cmd == "profiles clone":
+		if err := need(4); err != nil {
+			return err
+		}
+		op, status := s.renameProfile, "renamed"
+		if arg(1) == "clone" {
+			op, status = s.CloneProfile, "cloned"
+		}
+		name, err := op(arg(2), arg(3))
+		if err != nil {
+			return err
+		}
+		return printStatus(out, o, map[string]string{"status": status, "profile": name}, status+" "+core.SanitizeName(arg(2))+" to "+name)
+	case cmd == "profiles show":
+		if err := need(3); err != nil {
+			return err
+		}
+		meta, err := s.ProfileMeta(arg(2))
+		if err != nil {
+			return err
+		}
+		return printResult(out, o, meta, func(w io.Writer) { printProfileMeta(w, meta) })
+	case cmd == "profiles set":
+		if err := need(4); err != nil {
+			return err
+		}
+		meta, err := s.ProfileMeta(arg(2))
+		if err != nil {
+			return err
+		}
+		value := strings.Join(o.args[4:], " ")
+		switch arg(3) {
+		case "description":
+			meta.Description = value
+		case "character":
+			meta.CharacterName = value
+		case "lifepath":
+			meta.LifePath = value
+		case "build":
+			meta.BuildNotes = value
+		case "color":
+			meta.Color = value
+		case "icon":
+			meta.Icon = value
+		case "mods":
+			meta.Mods = strings.Split(value, ",")
+		default:
+			return errUsage
+		}
+		if meta, err = s.SetProfileMeta(arg(2), meta); err != nil {
+			return err
+		}
+		return printResult(out, o, meta, func(w io.Writer) { printProfileMeta(w, meta) })
+	case cmd == "saves list":
+		if err := need(3); err != nil {
+			return err
+		}
+		profile := core.SanitizeName(arg(2))
+		if !dirExists(filepath.Join(s.ProfilesDir(), profile)) {
+			return fmt.Errorf("profile %s %w", profile, core.ErrNotFound)
+		}
+		saves := s.Saves(profile)
+		if len(o.tags) > 0 || o.starred {
+			saves = filterSaves(saves, o.tags, o.starred)
+		}
+		return printResult(out, o, saves, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "NAME\tTYPE\tMODIFIED\tLEVEL\tPLAYTIME\tQUEST\tTAGS")
+			for _, sv := range saves {
+				name, quest := sv.Name, sv.QuestTitle
+				if sv.Favorite {
+					name = "*" + name
+				}
+				if sv.Label != "" {
+					quest = sv.Label
+				}
+				if sv.Corrupt {
+					quest = "DAMAGED: " + sv.Problem
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", name, sv.Type, sv.Modified, sv.Level, sv.Playtime, quest, strings.Join(sv.Tags, ", "))
+			}
+			tw.Flush()
+		})
+	case cmd == "saves tag", cmd == "saves untag", cmd == "saves star", cmd == "saves unstar", cmd == "saves label":
+		if err := need(4); err != nil {
+			return err
+		}
+		var u core.SaveMetaUpdate
+		switch rest := o.args[4:]; arg(1) {
+		case "tag", "untag":
+			if len(rest) == 0 {
+				return errUsage
+			}
+			if arg(1) == "tag" {
+				u.AddTags = rest
+			} else {
+				u.RemoveTags = rest
+			}
+		case "star", "unstar":
+			starred := arg(1) == "star"
+			u.Favorite = &starred
+		case "label":
+			label := strings.Join(rest, " ")
+			u.Label = &label
+		}
+		meta, err := s.UpdateSaveMeta(arg(2), arg(3), u)
+		if err != nil {
+			return err
+		}
+		return printResult(out, o, meta, func(w io.Writer) {
+			fmt.Fprintf(w, "%s: tags [%s] favorite %v label %q\n", core.SanitizeName(arg(3)), strings.Join(meta.Tags, ", "), meta.Favorite, meta.Label)
+		})
+	case cmd == "prune policy":
+		if err := need(3); err != nil {
+			return err
+		}
+		policy, err := s.PrunePolicy(arg(2))
+		if err != nil {
+			return err
+		}
+		if len(o.args) > 3 {
+			for _, kv := range o.args[3:] {
+				if err := setPruneOption(&policy, kv); err != nil {
+					return err
+				}
+			}
+			if policy, err = s.SetPrunePolicy(arg(2), policy); err != nil {
+				return err
+			}
+		}
+		return printResult(out, o, policy, func(w io.Writer) { printPrunePolicy(w, policy) })
+	case cmd == "trash list":
+		items := s.Trash()
+		return printResult(out, o, items, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tKIND\tDELETED\tSIZE\tORIGIN")
+			for _, it := range items {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", it.ID, it.Kind, it.Deleted.Format("2006-01-02 15:04:05"), it.Size, it.Origin)
+			}
+			tw.Flush()
+		})
+	case cmd == "trash restore":
+		if err := need(3); err != nil {
+			return err
+		}
+		item, err := s.RestoreTrash(arg(2), arg(3))
+		if err != nil {
+			return err
+		}
+		return printStatus(out, o, item, "restored "+item.Origin)
+	case cmd == "trash purge":
+		n, err := s.PurgeTrash(arg(2))
+		if err != nil {
+			return err
+		}
+		return printStatus(out, o, map[string]int{"purged": n}, fmt.Sprintf("purged %d items", n))
+	case cmd == "snapshot list":
+		if err := need(3); err != nil {
+			return err
+		}
+		snaps := s.Snapshots(core.SanitizeName(arg(2)))
+		return printResult(out, o, snaps, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tKIND\tCREATED\tFILES\tNAME")
+			for _, snap := range snaps {
+				name := snap.Name
+				if snap.Pinned {
+					name += " (pinned)"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", snap.ID, snap.Kind, snap.Created.Format("2006-01-02 15:04:05"), snap.Count, name)
+			}
+			tw.Flush()
+		})
+	case cmd == "snapshot create":
+		if err := need(3); err != nil {
+			return err
+		}
+		snap, err := s.TakeSnapshot(arg(2), strings.Join(o.args[3:], " "))
+		if err != nil {
+			return err
+		}
+		return printStatus(out, o, snap, "created snapshot "+snap.ID)
+	case cmd == "snapshot restore":
+		if err := need(4); err != nil {
+			return err
+		}
+		backup, err := s.RestoreSnapshot(arg(2), arg(3))
+		if err != nil {
+			return err
+		}
+		return printStatus(out, o, map[string]string{"status": "restored", "backup": backup},
+			"restored snapshot "+arg(3)+"; previous state kept as snapshot "+backup)
+	}
+	switch arg(0) {
+	case "load":
+		if err := need(2); err != nil {
+			return err
+		}
+		problems, err := s.LoadProfile(arg(1), o.force)
+		if err != nil {
+			return err
+		}
+		name := core.SanitizeName(arg(1))
+		if problems == nil {
+			problems = []core.SaveProblem{}
+		}
+		return printResult(out, o, map[string]any{"status": "loaded", "profile": name, "warnings": problems}, func(w io.Writer) {
+			fmt.Fprintf(w, "loaded profile %s\n", name)
+			for _, p := range problems {
+				fmt.Fprintf(w, "  warning: %s looks damaged: %s\n", p.Name, p.Reason)
+			}
+		})
+	case "recover":
+		rec, err := s.RecoverSwitch(o.force)
+		if err != nil {
+			return err
+		}
+		if rec.Pending {
+			if err := printResult(out, o, rec, func(w io.Writer) {
+				fmt.Fprintf(w, "rollback failed again: %s\nuse --force to leave the save folder as it is\n", rec.Error)
+			}); err != nil {
+				return err
+			}
+			return &silentError{core.ErrSwitchPending}
+		}
+		msg := "rolled back the switch to " + rec.Target + ", restored " + rec.Restored
+		if rec.Cleared {
+			msg = "cleared the switch journal; the save folder was left as it is"
+		}
+		return printStatus(out, o, rec, msg)
+	case "import":
+		if err := need(2); err != nil {
+			return err
+		}
+		if err := s.ImportProfile(arg(1)); err != nil {
+			return err
+		}
+		return printStatus(out, o, map[string]string{"status": "imported", "profile": core.SanitizeName(arg(1))}, "imported game saves into "+core.SanitizeName(arg(1)))
+	case "import-archive":
+		if err := need(2); err != nil {
+			return err
+		}
+		res, err := s.importArchiveFile(arg(1), arg(2), o.merge)
+		if err != nil {
+			return err
+		}
+		return printResult(out, o, res, func(w io.Writer) {
+			for _, sv := range res.Saves {
+				fmt.Fprintf(w, "%-8s %s\n", sv.Action, sv.Name)
+			}
+			fmt.Fprintf(w, "imported %d saves into %s\n", len(res.Saves), res.Profile)
+		})
+	case "verify-archive":
+		if err := need(2); err != nil {
+			return err
+		}
+		v, err := s.verifyArchiveFile(arg(1))
+		if err != nil {
+			return err
+		}
+		return printStatus(out, o, v, describeVerification(v))
+	case "prune":
+		if err := need(2); err != nil {
+			return err
+		}
+		res, err := s.Prune(arg(1), o.dryRun)
+		if err != nil {
+			return err
+		}
+		return printResult(out, o, res, func(w io.Writer) {
+			verb := "removed"
+			if res.DryRun {
+				verb = "would remove"
+			}
+			for _, c := range res.Remove {
+				fmt.Fprintf(w, "%s %s (%s)\n", verb, c.Name, c.Reason)
+			}
+			for _, e := range res.Errors {
+				fmt.Fprintf(w, "failed %s\n", e)
+			}
+			fmt.Fprintf(w, "%s %d saves, kept %d\n", verb, len(res.Remove), res.Kept)
+		})
+	case "signing-key":
+		key, err := s.SigningKey()
+		if err != nil {
+			return err
+		}
+		return printStatus(out, o, key, "key "+key.KeyID+"\npublic key "+key.PublicKey)
+	case "trusted-keys":
+		keys, err := s.TrustedKeys()
+		if err != nil {
+			return err
+		}
+		return printResult(out, o, keys, func(w io.Writer) {
+			for _, k := range keys {
+				fmt.Fprintf(w, "%s %s\n", k.KeyID, k.Name)
+			}
+		})
+	case "trust-key":
+		if err := need(3); err != nil {
+			return err
+		}
+		if err := s.TrustKey(arg(1), strings.Join(o.args[2:], " ")); err != nil {
+			return err
+		}
+		return printStatus(out, o, map[string]string{"status": "trusted"}, "trusted "+arg(1))
+	case "distrust-key":
+		if err := need(2); err != nil {
+			return err
+		}
+		if err := s.DistrustKey(arg(1)); err != nil {
+			return err
+		}
+		return printStatus(out, o, map[string]string{"status": "deleted"}, "removed "+arg(1))
+	case "copy-save":
+		if err := need(4); err != nil {
+			return err
+		}
+		dest, err := s.CopySave(arg(1), arg(2), arg(3), o.force)
+		if err != nil {
+			return err
+		}
+		return printStatus(out, o, map[string]string{"status": "copied", "dest": dest}, "copied to "+dest)
+	case "diff":
+		if err := need(3); err != nil {
+			return err
+		}
+		from, to := core.ParseSaveRef(arg(1), ""), core.ParseSaveRef(arg(2), "")
+		if from.Profile == "" || to.Profile == "" {
+			return fmt.Errorf("%w: saves must be given as profile/save", core.ErrInvalid)
+		}
+		d, err := s.DiffSaves(from, to)
+		if err != nil {
+			return err
+		}
+		return printResult(out, o, d, func(w io.Writer) { printSaveDiff(w, d) })
+	case "timeline":
+		if err := need(2); err != nil {
+			return err
+		}
+		tl, err := s.Timeline(arg(1))
+		if err != nil {
+			return err
+		}
+		return printResult(out, o, tl, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "PLAYTIME\tLEVEL\tCRED\tFINISHED\tSAVE\tQUEST\tNOTES")
+			for _, p := range tl.Points {
+				fmt.Fprintf(tw, "%s\t%g\t%g\t%d\t%s\t%s\t%s\n", formatSeconds(p.PlayTime), p.Level, p.StreetCred, p.QuestsFinished, p.Name, p.Quest.Title, strings.Join(p.Regressions, "; "))
+			}
+			tw.Flush()
+			if len(tl.Skipped) > 0 {
+				fmt.Fprintf(w, "unreadable: %s\n", strings.Join(tl.Skipped, ", "))
+			}
+		})
+	case "quests":
+		if err := need(2); err != nil {
+			return err
+		}
+		progress, err := s.QuestProgress(arg(1))
+		if err != nil {
+			return err
+		}
+		return printResult(out, o, progress, func(w io.Writer) {
+			for _, c := range progress.Categories {
+				fmt.Fprintf(w, "%s: %d of %d touched (%.1f%%), %d finished\n", c.Type, c.Touched, c.Total, c.Coverage, c.Finished)
+				for _, q := range c.Quests {
+					if q.Status != core.QuestUntouched || o.all {
+						fmt.Fprintf(w, "  %-9s %s\n", q.Status, q.Title)
+					}
+				}
+			}
+		})
+	case "search":
+		if err := need(2); err != nil {
+			return err
+		}
+		res, err := s.Search(strings.Join(o.args[1:], " "), 0)
+		if err != nil {
+			return err
+		}
+		return printResult(out, o, res, func(w io.Writer) {
+			tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "PROFILE\tSAVE\tTYPE\tLEVEL\tPLAYTIME\tQUEST")
+			for _, r := range res.Results {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%g\t%s\t%s\n", r.Profile, r.Name, r.Type, r.Level, formatSeconds(r.PlayTime), r.QuestTitle)
+			}
+			tw.Flush()
+			fmt.Fprintf(w, "%d of %d matches\n", len(res.Results), res.Total)
+		})
+	case "export":
+		if err := need(2); err != nil {
+			return err
+		}
+		return s.exportToFile(out, o, arg(1))
+	case "bulk":
+		if err := need(3); err != nil {
+			return err
+		}
+		return s.runBulk(out, o)
+	case "verify":
+		if err := need(2); err != nil {
+			return err
+		}
+		res, err := s.Verify(arg(1), o.refresh)
+		if err != nil {
+			return err
+		}
+		broken := 0
+		for _, r := range res {
+			if !r.OK {
+				broken++
+			}
+		}
+		if err := printResult(out, o, res, func(w io.Writer) {
+			for _, r := range res {
+				if r.OK {
+					fmt.Fprintf(w, "ok       %s\n", r.Name)
+				} else {
+					fmt.Fprintf(w, "damaged  %s: %s\n", r.Name, r.Reason)
+				}
+			}
+		}); err != nil {
+			return err
+		}
+		if broken > 0 {
+			// The report is already printed; the exit code tells scripts.
+			return &silentError{fmt.Errorf("%d damaged saves", broken)}
+		}
+		return nil
+	}
+	return errUsage
+}
+
+func (s *server) exportToFile(out io.Writer, o cliOptions, profile string) error {
+	opts := o.export
+	opts.Force = o.force
+	if opts.Format == "" && strings.HasSuffix(strings.ToLower(o.output), "."+core.FormatTarZst) {
+		opts.Format = core.FormatTarZst
+	}
+	exp, err := s.NewExport(profile, opts)
+	if err != nil {
+		return err
+	}
+	dest := o.output
+	if dest == "" {
+		dest = exp.Filename()
+	}
+	// Write next to the destination so a failed export leaves no partial file.
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".export_*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	err = exp.Stream(tmp)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dest)
+	}
+	if err != nil {
+		return err
+	}
+	return printStatus(out, o, map[string]string{"status": "exported", "file": dest}, "exported "+profile+" to "+dest)
+}
+
+// runBulk handles "bulk <op> <profile> ...". Saves come from --saves or,
+// without it, from the filter flags.
+func (s *server) runBulk(out io.Writer, o cliOptions) error {
+	op, profile, rest := o.args[1], o.args[2], o.args[3:]
+	req := core.BulkRequest{Op: op, Profile: profile, Saves: o.export.Saves, Force: o.force}
+	filter := o.filter
+	filter.Tags = o.tags
+	if !filter.Empty() {
+		req.Filter = &filter
+	}
+	switch op {
+	case core.BulkCopy, core.BulkMove:
+		if len(rest) != 1 {
+			return errUsage
+		}
+		req.Target = rest[0]
+	case "tag", "untag":
+		if len(rest) == 0 {
+			return errUsage
+		}
+		req.Op = core.BulkTag
+		if op == "tag" {
+			req.Tags.AddTags = rest
+		} else {
+			req.Tags.RemoveTags = rest
+		}
+	case core.BulkExport:
+		saves, err := s.SelectSaves(profile, req.Saves, req.Filter)
+		if err != nil {
+			return err
+		}
+		if len(saves) == 0 {
+			return fmt.Errorf("%w: no saves selected", core.ErrInvalid)
+		}
+		o.export.Saves = saves
+		return s.exportToFile(out, o, profile)
+	}
+	res, err := s.Bulk(req)
+	if err != nil {
+		return err
+	}
+	if err := printResult(out, o, res, func(w io.Writer) {
+		for _, it := range res.Items {
+			switch {
+			case it.Error != "":
+				fmt.Fprintf(w, "%-8s %s: %s\n", it.Status, it.Name, it.Error)
+			case it.Dest != "" && it.Dest != it.Name:
+				fmt.Fprintf(w, "%-8s %s -> %s\n", it.Status, it.Name, it.Dest)
+			default:
+				fmt.Fprintf(w, "%-8s %s\n", it.Status, it.Name)
+			}
+		}
+		fmt.Fprintf(w, "%s: %d ok, %d failed\n", req.Op, res.OK, res.Failed)
+	}); err != nil {
+		return err
+	}
+	if res.Failed > 0 {
+		return &silentError{fmt.Errorf("%d saves failed", res.Failed)}
+	}
+	return nil
+}
+
+func (s *server) importArchiveFile(file, profile string, merge bool) (core.ImportResult, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return core.ImportResult{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return core.ImportResult{}, err
+	}
+	return s.ImportArchive(f, info.Size(), core.ImportOptions{Profile: profile, ArchiveName: file, Merge: merge})
+}
+
+func (s *server) verifyArchiveFile(file string) (core.ArchiveVerification, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return core.ArchiveVerification{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return core.ArchiveVerification{}, err
+	}
+	return s.VerifyArchive(f, info.Size())
+}
+
+func describeVerification(v core.ArchiveVerification) string {
+	switch {
+	case v.OwnKey:
+		return fmt.Sprintf("signed by this computer (key %s); %d files match the manifest", v.KeyID, v.Files)
+	case v.Trusted:
+		return fmt.Sprintf("signed by %s (trusted key %s); %d files match the manifest", v.TrustedName, v.KeyID, v.Files)
+	case v.Signed:
+		claim := ""
+		if v.Signer != "" {
+			claim = fmt.Sprintf(", claims to be %q", v.Signer)
+		}
+		return fmt.Sprintf("signed with unverified key %s%s; %d files match the manifest", v.KeyID, claim, v.Files)
+	case v.Manifest:
+		return fmt.Sprintf("unsigned; %d files match the manifest", v.Files)
+	}
+	return "no manifest; contents not checked"
+}
+
+// setPruneOption applies one key=value argument of "prune policy".
+func setPruneOption(p *core.PrunePolicy, kv string) error {
+	key, value, ok := strings.Cut(kv, "=")
+	if !ok {
+		return fmt.Errorf("%w: expected key=value, got %q", core.ErrInvalid, kv)
+	}
+	switch key {
+	case "auto", "quick", "manual":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%w: %s needs a number", core.ErrInvalid, key)
+		}
+		switch key {
+		case "auto":
+			p.KeepAuto = n
+		case "quick":
+			p.KeepQuick = n
+		default:
+			p.KeepManual = n
+		}
+	case "quick-per-quest", "on-exit":
+		on := value == "on" || value == "true" || value == "1"
+		if !on && value != "off" && value != "false" && value != "0" {
+			return fmt.Errorf("%w: %s needs on or off", core.ErrInvalid, key)
+		}
+		if key == "on-exit" {
+			p.OnGameExit = on
+		} else {
+			p.QuickPerQuest = on
+		}
+	default:
+		return fmt.Errorf("%w: unknown policy option %s", core.ErrInvalid, key)
+	}
+	return nil
+}
+
+func printPrunePolicy(w io.Writer, p core.PrunePolicy) {
+	limit := func(n int) string {
+		if n == 0 {
+			return "all"
+		}
+		return strconv.Itoa(n)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "keep autosaves\t%s\n", limit(p.KeepAuto))
+	fmt.Fprintf(tw, "keep quicksaves\t%s\n", limit(p.KeepQuick))
+	fmt.Fprintf(tw, "keep manual saves\t%s\n", limit(p.KeepManual))
+	fmt.Fprintf(tw, "one quicksave per quest\t%v\n", p.QuickPerQuest)
+	fmt.Fprintf(tw, "prune on game exit\t%v\n", p.OnGameExit)
+	tw.Flush()
+}
+
+func printSaveDiff(w io.Writer, d core.SaveDiff) {
+	fmt.Fprintf(w, "%s -> %s\n", d.From.SaveRef, d.To.SaveRef)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, f := range d.Fields {
+		if f.Delta != nil {
+			fmt.Fprintf(tw, "%s\t%v\t%v\t%+g\n", f.Field, f.From, f.To, *f.Delta)
+		} else {
+			fmt.Fprintf(tw, "%s\t%v\t%v\n", f.Field, f.From, f.To)
+		}
+	}
+	tw.Flush()
+	for _, l := range []struct {
+		label string
+		list  []core.QuestRef
+	}{{"started", d.QuestsStarted}, {"finished", d.QuestsFinished}, {"dropped", d.QuestsDropped}} {
+		for _, q := range l.list {
+			title := q.Title
+			if title == "" {
+				title = q.Path
+			}
+			fmt.Fprintf(w, "%-8s %s\n", l.label, title)
+		}
+	}
+}
+
+// formatSeconds shows a play time as hours and minutes.
+func formatSeconds(sec float64) string {
+	minutes := int(sec) / 60
+	return fmt.Sprintf("%dh %02dm", minutes/60, minutes%60)
+}
+
+func printProfileMeta(w io.Writer, meta core.ProfileMeta) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "character\t%s\n", meta.CharacterName)
+	fmt.Fprintf(tw, "life path\t%s\n", meta.LifePath)
+	fmt.Fprintf(tw, "description\t%s\n", meta.Description)
+	fmt.Fprintf(tw, "build\t%s\n", meta.BuildNotes)
+	fmt.Fprintf(tw, "color\t%s\n", meta.Color)
+	fmt.Fprintf(tw, "icon\t%s\n", meta.Icon)
+	fmt.Fprintf(tw, "mods\t%s\n", strings.Join(meta.Mods, ", "))
+	if meta.Created != nil {
+		fmt.Fprintf(tw, "created\t%s\n", meta.Created.Format("2006-01-02 15:04:05"))
+	}
+	if meta.LastPlayed != nil {
+		fmt.Fprintf(tw, "last played\t%s\n", meta.LastPlayed.Format("2006-01-02 15:04:05"))
+	}
+	tw.Flush()
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return strings.TrimSpace(s[:i])
+	}
+	return s
+}
+
+// silentError fails the command without printing anything further.
+type silentError struct{ err error }
+
+func (e *silentError) Error() string { return e.err.Error() }
+
+func printResult(out io.Writer, o cliOptions, v any, text func(io.Writer)) error {
+	if o.json {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	text(out)
+	return nil
+}
+
+func printStatus(out io.Writer, o cliOptions, v any, msg string) error {
+	return printResult(out, o, v, func(w io.Writer) { fmt.Fprintln(w, msg) })
+}
+
+func printCLIError(o cliOptions, err error) {
+	var silent *silentError
+	if errors.As(err, &silent) {
+		return
+	}
+	var damaged *core.DamagedError
+	errors.As(err, &damaged)
+	if o.json {
+		resp := map[string]any{"error": err.Error()}
+		if damaged != nil {
+			resp["problems"] = damaged.Problems
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(resp)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "cybersaver: %v\n", err)
+	if damaged != nil {
+		for _, p := range damaged.Problems {
+			fmt.Fprintf(os.Stderr, "  %s: %s\n", p.Name, p.Reason)
+		}
+		fmt.Fprintln(os.Stderr, "use --force to continue anyway")
+	}
+}
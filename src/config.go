@@ -6,14 +6,18 @@ import (
 	"os"
 	"path/filepath"
 
+	"cybersaver/core"
+
 	"github.com/sqweek/dialog"
 )
 
 type appConfig struct {
-	Port         int    `json:"port"`
-	GameSavePath string `json:"gameSavePath"`
-	ProfilesDir  string `json:"profilesDir"`
-	WizardDone   bool   `json:"wizardDone"`
+	Port         int               `json:"port"`
+	GameSavePath string            `json:"gameSavePath"`
+	ProfilesDir  string            `json:"profilesDir"`
+	WizardDone   bool              `json:"wizardDone"`
+	Backup       core.BackupConfig `json:"backup"`
+	TrashDays    int               `json:"trashDays,omitempty"`
 }
 
 func configPath() string {
@@ -67,7 +71,7 @@ func requirePort(cfg appConfig) appConfig {
 	return cfg
 }
 
-func runSetupWizard(cfg appConfig, s *server) appConfig {
+func runSetupWizard(cfg appConfig) appConfig {
 	if cfg.WizardDone {
 		return cfg
 	}
@@ -80,16 +84,13 @@ func runSetupWizard(cfg appConfig, s *server) appConfig {
 	}
 
 	// Game saves folder
-	if uri, err := dialog.Directory().Title("Select Cyberpunk Saves Folder").SetStartDir(s.gameSavePath).Browse(); err == nil && uri != "" {
+	if uri, err := dialog.Directory().Title("Select Cyberpunk Saves Folder").SetStartDir(core.DefaultGameSavePath()).Browse(); err == nil && uri != "" {
 		cfg.GameSavePath = uri
-		s.gameSavePath = uri
-		s.gamePathExists = dirExists(uri)
 	}
 
 	// Profiles folder
-	if uri, err := dialog.Directory().Title("Select Profiles Folder").SetStartDir(s.profilesDir).Browse(); err == nil && uri != "" {
+	if uri, err := dialog.Directory().Title("Select Profiles Folder").SetStartDir(defaultProfilesDir()).Browse(); err == nil && uri != "" {
 		cfg.ProfilesDir = uri
-		s.profilesDir = uri
 	}
 
 	cfg.WizardDone = true
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// attachConsole connects a GUI-subsystem build to the console it was started
+// from so command-line output is visible. Redirected output is left alone.
+func attachConsole() {
+	if _, err := os.Stdout.Stat(); err == nil {
+		return
+	}
+	const attachParentProcess = ^uintptr(0)
+	attach := syscall.NewLazyDLL("kernel32.dll").NewProc("AttachConsole")
+	if ok, _, _ := attach.Call(attachParentProcess); ok == 0 {
+		return
+	}
+	if out, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+		os.Stdout = out
+		os.Stderr = out
+	}
+}
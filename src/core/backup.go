@@ -0,0 +1,264 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const snapshotAuto = "auto"
+
+type BackupConfig struct {
+	IntervalMinutes int      `json:"intervalMinutes"`
+	OnGameExit      bool     `json:"onGameExit"`
+	Profiles        []string `json:"profiles"`
+	KeepLast        int      `json:"keepLast"`
+	KeepDaily       int      `json:"keepDaily"`
+	KeepWeekly      int      `json:"keepWeekly"`
+}
+
+type BackupStatus struct {
+	Config     BackupConfig `json:"config"`
+	LastRun    *time.Time   `json:"lastRun,omitempty"`
+	LastReason string       `json:"lastReason,omitempty"`
+	LastError  string       `json:"lastError,omitempty"`
+	Created    []string     `json:"created"`
+	Pruned     int          `json:"pruned"`
+	NextRun    *time.Time   `json:"nextRun,omitempty"`
+}
+
+// backupScheduler takes automatic snapshots on an interval and when the game
+// exits, then prunes old automatic snapshots by the retention rules.
+type backupScheduler struct {
+	m      *Manager
+	mu     sync.Mutex
+	busy   sync.Mutex
+	status BackupStatus
+	reset  chan struct{}
+}
+
+func newBackupScheduler(m *Manager, cfg BackupConfig) *backupScheduler {
+	return &backupScheduler{m: m, status: BackupStatus{Config: cfg, Created: []string{}}, reset: make(chan struct{}, 1)}
+}
+
+func (b *backupScheduler) loop() {
+	for {
+		cfg := b.config()
+		var tick <-chan time.Time
+		if cfg.IntervalMinutes > 0 {
+			next := time.Now().Add(time.Duration(cfg.IntervalMinutes) * time.Minute)
+			b.mu.Lock()
+			b.status.NextRun = &next
+			b.mu.Unlock()
+			tick = time.After(time.Until(next))
+		} else {
+			b.mu.Lock()
+			b.status.NextRun = nil
+			b.mu.Unlock()
+		}
+		select {
+		case <-tick:
+			b.backup("interval")
+		case <-b.reset:
+		}
+	}
+}
+
+// gameExited runs the on-exit backup, if configured, and returns when it is
+// done.
+func (b *backupScheduler) gameExited() {
+	if b.config().OnGameExit {
+		b.backup("game exit")
+	}
+}
+
+func (b *backupScheduler) config() BackupConfig {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.status.Config
+}
+
+func (b *backupScheduler) setConfig(cfg BackupConfig) {
+	b.mu.Lock()
+	b.status.Config = cfg
+	b.mu.Unlock()
+	select {
+	case b.reset <- struct{}{}:
+	default:
+	}
+}
+
+// profileRenamed keeps a renamed profile in the backup schedule. Persisting
+// the changed config is up to the frontend.
+func (b *backupScheduler) profileRenamed(from, to string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	profiles := append([]string(nil), b.status.Config.Profiles...)
+	for i, p := range profiles {
+		if p == from {
+			profiles[i] = to
+		}
+	}
+	b.status.Config.Profiles = profiles
+}
+
+func (b *backupScheduler) current() BackupStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := b.status
+	st.Created = append([]string{}, st.Created...)
+	return st
+}
+
+func (b *backupScheduler) backup(reason string) {
+	b.busy.Lock()
+	defer b.busy.Unlock()
+	cfg := b.config()
+	profiles := cfg.Profiles
+	if len(profiles) == 0 {
+		if active := b.m.ActiveProfile(); active != "" {
+			profiles = []string{active}
+		}
+	}
+	var created, errs []string
+	pruned := 0
+	for _, p := range profiles {
+		p = SanitizeName(p)
+		if p == "" {
+			continue
+		}
+		j := b.m.startJob("backup", p)
+		id, err := autoSnapshot(b.m.profilesDir, p, reason, j.progress)
+		j.finish(err)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p, err))
+			continue
+		}
+		if id != "" {
+			created = append(created, p+"/"+id)
+		}
+		n, err := pruneSnapshots(b.m.profilesDir, p, cfg, time.Now())
+		pruned += n
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: prune: %v", p, err))
+		}
+	}
+	if pruned > 0 {
+		if _, err := newBlobStore(b.m.profilesDir).gc(b.m.profilesDir); err != nil {
+			errs = append(errs, "gc: "+err.Error())
+		}
+	}
+	now := time.Now()
+	b.mu.Lock()
+	b.status.LastRun = &now
+	b.status.LastReason = reason
+	b.status.LastError = strings.Join(errs, "; ")
+	b.status.Created = append([]string{}, created...)
+	b.status.Pruned = pruned
+	b.mu.Unlock()
+	if len(errs) > 0 {
+		log.Printf("scheduled backup (%s) had errors: %s", reason, strings.Join(errs, "; "))
+	}
+}
+
+// autoSnapshot snapshots a profile unless it is unchanged since its newest
+// snapshot. It returns the new snapshot id, or "" when nothing was taken.
+func autoSnapshot(profilesDir, profile, reason string, progress progressFunc) (string, error) {
+	if snaps := listSnapshots(profilesDir, profile); len(snaps) > 0 {
+		latest, err := loadSnapshot(profilesDir, profile, snaps[0].ID)
+		if err == nil {
+			cur, err := currentFiles(filepath.Join(profilesDir, profile))
+			if err != nil {
+				return "", err
+			}
+			d := diffFiles(latest.Files, cur)
+			if len(d.FilesAdded)+len(d.FilesRemoved)+len(d.FilesChanged) == 0 {
+				return "", nil
+			}
+		}
+	}
+	snap, err := createSnapshot(profilesDir, profile, "Automatic ("+reason+")", snapshotAuto, progress)
+	if err != nil {
+		return "", err
+	}
+	return snap.ID, nil
+}
+
+// pruneSnapshots deletes automatic snapshots not kept by any retention rule.
+// Manual and pinned snapshots are never touched, and with no rules configured
+// nothing is pruned.
+func pruneSnapshots(profilesDir, profile string, cfg BackupConfig, now time.Time) (int, error) {
+	if cfg.KeepLast <= 0 && cfg.KeepDaily <= 0 && cfg.KeepWeekly <= 0 {
+		return 0, nil
+	}
+	var autos []Snapshot
+	for _, snap := range listSnapshots(profilesDir, profile) {
+		if snap.Kind == snapshotAuto && !snap.Pinned {
+			autos = append(autos, snap)
+		}
+	}
+	sort.Slice(autos, func(i, j int) bool { return autos[i].Created.After(autos[j].Created) })
+	keep := map[string]bool{}
+	for i := 0; i < cfg.KeepLast && i < len(autos); i++ {
+		keep[autos[i].ID] = true
+	}
+	keepNewestPer(autos, keep, now.AddDate(0, 0, -cfg.KeepDaily), func(t time.Time) string {
+		return t.Format("2006-01-02")
+	})
+	keepNewestPer(autos, keep, now.AddDate(0, 0, -7*cfg.KeepWeekly), func(t time.Time) string {
+		y, w := t.ISOWeek()
+		return fmt.Sprintf("%d-%02d", y, w)
+	})
+	pruned := 0
+	for _, snap := range autos {
+		if keep[snap.ID] {
+			continue
+		}
+		if err := deleteSnapshot(profilesDir, profile, snap.ID); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// keepNewestPer marks the newest snapshot in each period (as named by key) that
+// starts after since. autos must be sorted newest first.
+func keepNewestPer(autos []Snapshot, keep map[string]bool, since time.Time, key func(time.Time) string) {
+	seen := map[string]bool{}
+	for _, snap := range autos {
+		if !snap.Created.After(since) {
+			break
+		}
+		k := key(snap.Created.Local())
+		if !seen[k] {
+			seen[k] = true
+			keep[snap.ID] = true
+		}
+	}
+}
+
+func (c BackupConfig) Validate() error {
+	if c.IntervalMinutes < 0 || c.KeepLast < 0 || c.KeepDaily < 0 || c.KeepWeekly < 0 {
+		return fmt.Errorf("%w: values must not be negative", ErrInvalid)
+	}
+	return nil
+}
+
+func (m *Manager) BackupConfig() BackupConfig { return m.backups.config() }
+
+// SetBackupConfig applies a new schedule right away. Persisting it is up to
+// the frontend.
+func (m *Manager) SetBackupConfig(cfg BackupConfig) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	m.backups.setConfig(cfg)
+	return nil
+}
+
+func (m *Manager) BackupStatus() BackupStatus { return m.backups.current() }
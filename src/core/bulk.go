@@ -0,0 +1,234 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Bulk operations.
+const (
+	BulkCopy   = "copy"
+	BulkMove   = "move"
+	BulkDelete = "delete"
+	BulkTag    = "tag"
+	BulkExport = "export"
+)
+
+// SaveFilter selects saves by what SaveInfo knows about them. Zero fields
+// match everything.
+type SaveFilter struct {
+	// Type is Auto, Quick, Manual or Other.
+	Type string `json:"type,omitempty"`
+	// OlderThanDays matches saves last written more than that many days ago.
+	OlderThanDays int `json:"olderThanDays,omitempty"`
+	// Tags matches saves that have every one of them.
+	Tags []string `json:"tags,omitempty"`
+	// SkipFavorites leaves starred saves out.
+	SkipFavorites bool `json:"skipFavorites,omitempty"`
+}
+
+// Empty reports whether f matches every save.
+func (f SaveFilter) Empty() bool {
+	return f.Type == "" && f.OlderThanDays == 0 && len(f.Tags) == 0 && !f.SkipFavorites
+}
+
+func (f SaveFilter) match(s SaveInfo, now time.Time) bool {
+	if f.Type != "" && s.Type != f.Type {
+		return false
+	}
+	for _, t := range f.Tags {
+		if !s.HasTag(t) {
+			return false
+		}
+	}
+	if f.SkipFavorites && s.Favorite {
+		return false
+	}
+	if f.OlderThanDays > 0 {
+		mod, err := time.ParseInLocation("2006-01-02 15:04:05", s.Modified, time.Local)
+		if err != nil || now.Sub(mod) < time.Duration(f.OlderThanDays)*24*time.Hour {
+			return false
+		}
+	}
+	return true
+}
+
+type BulkRequest struct {
+	Op      string `json:"op"`
+	Profile string `json:"profile"`
+	// Saves names the saves to act on; when empty Filter picks them.
+	Saves  []string    `json:"saves,omitempty"`
+	Filter *SaveFilter `json:"filter,omitempty"`
+	// Target is the profile saves are copied or moved into.
+	Target string `json:"target,omitempty"`
+	// Tags is applied to every save by a tag operation.
+	Tags SaveMetaUpdate `json:"tags"`
+	// Force copies or moves damaged saves too.
+	Force bool `json:"force,omitempty"`
+}
+
+type BulkItem struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Dest   string `json:"dest,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type BulkResult struct {
+	Job    string     `json:"job"`
+	Op     string     `json:"op"`
+	Items  []BulkItem `json:"items"`
+	OK     int        `json:"ok"`
+	Failed int        `json:"failed"`
+}
+
+// SelectSaves resolves the saves a bulk request names, or those matching
+// filter when names is empty.
+func (m *Manager) SelectSaves(profile string, names []string, filter *SaveFilter) ([]string, error) {
+	profile = SanitizeName(profile)
+	if profile == "" {
+		return nil, fmt.Errorf("%w: profile required", ErrInvalid)
+	}
+	if !dirExists(filepath.Join(m.profilesDir, profile)) {
+		return nil, fmt.Errorf("profile %s %w", profile, ErrNotFound)
+	}
+	if len(names) > 0 {
+		res := make([]string, 0, len(names))
+		for _, n := range names {
+			if n = SanitizeName(n); n != "" {
+				res = append(res, n)
+			}
+		}
+		return res, nil
+	}
+	if filter == nil {
+		return nil, fmt.Errorf("%w: saves or filter required", ErrInvalid)
+	}
+	now := time.Now()
+	res := []string{}
+	for _, s := range m.Saves(profile) {
+		if filter.match(s, now) {
+			res = append(res, s.Name)
+		}
+	}
+	return res, nil
+}
+
+// Bulk copies, moves, deletes or tags many saves as one job and waits for it.
+// A failing save does not stop the others; each gets its own result.
+func (m *Manager) Bulk(req BulkRequest) (BulkResult, error) {
+	j, names, err := m.startBulk(req)
+	if err != nil {
+		return BulkResult{}, err
+	}
+	return m.runBulk(j, req, names), nil
+}
+
+// StartBulk checks a bulk request and runs it in the background, returning
+// the job at once. Job reports its progress and the BulkResult so far.
+func (m *Manager) StartBulk(req BulkRequest) (JobStatus, error) {
+	j, names, err := m.startBulk(req)
+	if err != nil {
+		return JobStatus{}, err
+	}
+	st := j.status()
+	go m.runBulk(j, req, names)
+	return st, nil
+}
+
+func (m *Manager) startBulk(req BulkRequest) (*job, []string, error) {
+	switch req.Op {
+	case BulkCopy, BulkMove:
+		if SanitizeName(req.Target) == "" {
+			return nil, nil, fmt.Errorf("%w: target profile required", ErrInvalid)
+		}
+	case BulkDelete, BulkTag:
+	case BulkExport:
+		return nil, nil, fmt.Errorf("%w: export saves with NewExport", ErrInvalid)
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown operation %q", ErrInvalid, req.Op)
+	}
+	names, err := m.SelectSaves(req.Profile, req.Saves, req.Filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	j := m.startJob("bulk-"+req.Op, SanitizeName(req.Profile))
+	j.setResult(BulkResult{Job: j.p.ID, Op: req.Op, Items: []BulkItem{}})
+	return j, names, nil
+}
+
+func (m *Manager) runBulk(j *job, req BulkRequest, names []string) BulkResult {
+	profile := SanitizeName(req.Profile)
+	res := BulkResult{Job: j.p.ID, Op: req.Op, Items: make([]BulkItem, 0, len(names))}
+	for i, name := range names {
+		item := BulkItem{Name: name, Status: "ok"}
+		var err error
+		switch req.Op {
+		case BulkCopy:
+			var dest string
+			if dest, err = m.CopySave(profile, name, req.Target, req.Force); err == nil {
+				item.Dest = filepath.Base(dest)
+			}
+		case BulkMove:
+			item.Dest, err = m.moveSave(profile, name, SanitizeName(req.Target), req.Force)
+		case BulkDelete:
+			_, err = m.DeleteSave(profile, name)
+		case BulkTag:
+			_, err = m.UpdateSaveMeta(profile, name, req.Tags)
+		}
+		if err != nil {
+			item.Status, item.Error = "failed", err.Error()
+			res.Failed++
+		} else {
+			res.OK++
+		}
+		// Items is only appended to, so the copy handed to Job stays valid.
+		res.Items = append(res.Items, item)
+		j.setResult(res)
+		j.progress(i+1, len(names))
+	}
+	if res.Failed > 0 {
+		j.finish(fmt.Errorf("%d of %d saves failed", res.Failed, len(names)))
+	} else {
+		j.finish(nil)
+	}
+	return res
+}
+
+// moveSave moves a save, with its tags and label, into another profile and
+// returns its name there; an existing save of the same name is kept and the
+// moved one gets a suffix.
+func (m *Manager) moveSave(profile, name, target string, force bool) (string, error) {
+	src := filepath.Join(m.profilesDir, profile, name)
+	if !dirExists(src) {
+		return "", fmt.Errorf("save %s %w", name, ErrNotFound)
+	}
+	if target == profile {
+		return "", fmt.Errorf("%w: save is already in %s", ErrInvalid, target)
+	}
+	if c := m.integrity.check(src); !c.OK && !force {
+		return "", &DamagedError{Msg: "save looks damaged", Problems: []SaveProblem{{Name: name, Reason: c.Reason}}}
+	}
+	destDir := filepath.Join(m.profilesDir, target)
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return "", err
+	}
+	destName := name
+	if _, err := os.Stat(filepath.Join(destDir, destName)); err == nil {
+		destName = name + "_moved_" + time.Now().Format("20060102_150405")
+	}
+	if err := os.Rename(src, filepath.Join(destDir, destName)); err != nil {
+		return "", err
+	}
+	m.integrity.forget(src)
+	sm := m.SaveMeta(profile, name)
+	_ = editSaveMeta(m.profilesDir, profile, func(meta map[string]SaveMeta) { delete(meta, name) })
+	if !sm.empty() {
+		_ = editSaveMeta(m.profilesDir, target, func(meta map[string]SaveMeta) { meta[destName] = sm })
+	}
+	m.watcher.refresh(filepath.Join(m.profilesDir, profile))
+	m.watcher.refresh(destDir)
+	return destName, nil
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const jobProgressInterval = 250 * time.Millisecond
+
+// maxJobHistory is how many recent jobs Job can still report on.
+const maxJobHistory = 100
+
+// Event is a change notification: "game", "profile", "save" or "job", with
+// the matching payload.
+type Event struct {
+	Type string
+	Data any
+}
+
+// eventHub fans events out to every subscriber. Subscribers that
+// fall behind lose events rather than blocking publishers.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subs: map[chan Event]struct{}{}}
+}
+
+func (h *eventHub) publish(typ string, data any) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- Event{Type: typ, Data: data}:
+		default:
+		}
+	}
+}
+
+func (h *eventHub) subscribe() (chan Event, func()) {
+	ch := make(chan Event, 64)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+const (
+	jobRunning = "running"
+	jobDone    = "done"
+	jobFailed  = "failed"
+)
+
+var jobSeq atomic.Int64
+
+// progressFunc receives done/total counts from long operations; nil is allowed.
+type progressFunc func(done, total int)
+
+func (p progressFunc) report(done, total int) {
+	if p != nil {
+		p(done, total)
+	}
+}
+
+type JobProgress struct {
+	ID      string    `json:"id"`
+	Kind    string    `json:"kind"`
+	Profile string    `json:"profile"`
+	Status  string    `json:"status"`
+	Done    int       `json:"done"`
+	Total   int       `json:"total"`
+	Error   string    `json:"error,omitempty"`
+	Started time.Time `json:"started"`
+}
+
+// JobStatus is a job's progress together with the result it has so far, for
+// jobs that have one.
+type JobStatus struct {
+	JobProgress
+	Result any `json:"result,omitempty"`
+}
+
+// job reports the progress of a long-running operation as "job" events.
+type job struct {
+	hub    *eventHub
+	mu     sync.Mutex
+	p      JobProgress
+	result any
+	sent   time.Time
+}
+
+// jobList keeps the most recent jobs so they can be polled as well as
+// followed through events.
+type jobList struct {
+	mu   sync.Mutex
+	jobs []*job
+}
+
+func (l *jobList) add(j *job) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.jobs = append(l.jobs, j)
+	if len(l.jobs) > maxJobHistory {
+		l.jobs = append([]*job(nil), l.jobs[len(l.jobs)-maxJobHistory:]...)
+	}
+}
+
+func (l *jobList) get(id string) *job {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, j := range l.jobs {
+		if j.p.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+func (m *Manager) startJob(kind, profile string) *job {
+	j := &job{hub: m.events, p: JobProgress{
+		ID:      strconv.FormatInt(jobSeq.Add(1), 10),
+		Kind:    kind,
+		Profile: profile,
+		Status:  jobRunning,
+		Started: time.Now(),
+	}}
+	m.jobs.add(j)
+	j.publish()
+	return j
+}
+
+// Job reports on one of the recent jobs.
+func (m *Manager) Job(id string) (JobStatus, error) {
+	j := m.jobs.get(id)
+	if j == nil {
+		return JobStatus{}, fmt.Errorf("job %s %w", id, ErrNotFound)
+	}
+	return j.status(), nil
+}
+
+func (j *job) status() JobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return JobStatus{JobProgress: j.p, Result: j.result}
+}
+
+// setResult records what the job has produced so far for Job to return.
+func (j *job) setResult(v any) {
+	j.mu.Lock()
+	j.result = v
+	j.mu.Unlock()
+}
+
+// progress records done/total and publishes it, at most a few times a second
+// so per-file updates don't flood subscribers.
+func (j *job) progress(done, total int) {
+	j.mu.Lock()
+	j.p.Done, j.p.Total = done, total
+	quiet := done < total && time.Since(j.sent) < jobProgressInterval
+	j.mu.Unlock()
+	if !quiet {
+		j.publish()
+	}
+}
+
+func (j *job) finish(err error) {
+	j.mu.Lock()
+	j.p.Status = jobDone
+	if err != nil {
+		j.p.Status = jobFailed
+		j.p.Error = err.Error()
+	}
+	j.mu.Unlock()
+	j.publish()
+}
+
+func (j *job) publish() {
+	j.mu.Lock()
+	p := j.p
+	j.sent = time.Now()
+	j.mu.Unlock()
+	j.hub.publish("job", p)
+}
@@ -0,0 +1,411 @@
+package core
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Export archive formats.
+const (
+	FormatZip    = "zip"
+	FormatTarZst = "tar.zst"
+)
+
+const (
+	manifestName    = "manifest.json"
+	manifestVersion = 1
+)
+
+type ExportOptions struct {
+	// Format is FormatZip (the default) or FormatTarZst.
+	Format string
+	// Snapshot exports that snapshot instead of the profile's current files.
+	Snapshot string
+	// Saves limits the export to these save folders; empty means all.
+	Saves []string
+	// SkipScreenshots leaves out each save's screenshot.
+	SkipScreenshots bool
+	// SkipNotes leaves out profile.json with the profile's description.
+	SkipNotes bool
+	// IncludeSnapshots adds the profile's snapshots under .snapshots/, in
+	// full, whichever saves are selected.
+	IncludeSnapshots bool
+	// Force exports damaged saves instead of refusing.
+	Force bool
+	// Sign adds manifest.sig, signing the manifest with the local key. Only
+	// zip exports can be signed.
+	Sign bool
+	// Signer is a name recorded in the manifest, such as who exported it.
+	Signer string
+}
+
+// Manifest is written as manifest.json at the root of every export. It
+// lists each save with its metadata and every file with its SHA-256.
+type Manifest struct {
+	Version  int            `json:"version"`
+	Profile  string         `json:"profile"`
+	Snapshot string         `json:"snapshot,omitempty"`
+	Signer   string         `json:"signer,omitempty"`
+	Created  time.Time      `json:"created"`
+	Saves    []ManifestSave `json:"saves"`
+	Files    []ManifestFile `json:"files"`
+}
+
+type ManifestSave struct {
+	Name       string        `json:"name"`
+	Type       string        `json:"type"`
+	QuestTitle string        `json:"questTitle,omitempty"`
+	Metadata   *SaveMetadata `json:"metadata,omitempty"`
+}
+
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Export is a prepared profile export. NewExport does all the checks, so a
+// frontend can still report errors before it starts streaming.
+type Export struct {
+	m        *Manager
+	profile  string
+	format   string
+	manifest Manifest
+	entries  []exportEntry
+	key      ed25519.PrivateKey
+}
+
+type exportEntry struct {
+	name  string
+	size  int64
+	mod   time.Time
+	write func(io.Writer) error
+}
+
+// NewExport selects the files of a profile, or of one of its snapshots, to
+// export. Damaged saves are refused unless opts.Force is set.
+func (m *Manager) NewExport(profile string, opts ExportOptions) (*Export, error) {
+	profile = SanitizeName(profile)
+	if profile == "" {
+		return nil, fmt.Errorf("%w: profile required", ErrInvalid)
+	}
+	base := filepath.Join(m.profilesDir, profile)
+	if !dirExists(base) {
+		return nil, fmt.Errorf("profile %s %w", profile, ErrNotFound)
+	}
+	switch opts.Format {
+	case "":
+		opts.Format = FormatZip
+	case FormatZip, FormatTarZst:
+	default:
+		return nil, fmt.Errorf("%w: unknown export format %q", ErrInvalid, opts.Format)
+	}
+	e := &Export{m: m, profile: profile, format: opts.Format, manifest: Manifest{
+		Version:  manifestVersion,
+		Profile:  profile,
+		Snapshot: opts.Snapshot,
+		Signer:   strings.TrimSpace(opts.Signer),
+		Created:  time.Now().UTC(),
+		Saves:    []ManifestSave{},
+		Files:    []ManifestFile{},
+	}}
+
+	selected := map[string]bool{}
+	for _, name := range opts.Saves {
+		selected[SanitizeName(name)] = true
+	}
+	var files []SnapshotFile
+	var snap Snapshot
+	if opts.Snapshot != "" {
+		var err error
+		if snap, err = loadSnapshot(m.profilesDir, profile, opts.Snapshot); err != nil {
+			return nil, err
+		}
+		files = snap.Files
+	} else {
+		err := walkProfileFiles(base, func(rel, full string) error {
+			info, err := os.Stat(full)
+			if err != nil {
+				return err
+			}
+			files = append(files, SnapshotFile{Path: rel, Size: info.Size()})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	saves := map[string]bool{}
+	for _, f := range files {
+		save := saveOf(f.Path)
+		isSave := save != f.Path
+		switch {
+		case isSave && len(selected) > 0 && !selected[save]:
+			continue
+		case !isSave && (f.Path == profileMetaFile || f.Path == legacyNoteFile) && opts.SkipNotes:
+			continue
+		case !isSave && !isProfileFile(f.Path) && len(selected) > 0:
+			continue
+		case isSave && opts.SkipScreenshots && strings.HasPrefix(path.Base(f.Path), "screenshot."):
+			continue
+		}
+		if isSave {
+			saves[save] = true
+		}
+		e.entries = append(e.entries, e.fileEntry(base, snap, f))
+	}
+	for name := range selected {
+		if !saves[name] {
+			return nil, fmt.Errorf("save %s %w", name, ErrNotFound)
+		}
+	}
+
+	var problems []SaveProblem
+	for save := range saves {
+		ms := ManifestSave{Name: save, Type: classifySave(save)}
+		var meta SaveMetadata
+		var err error
+		if opts.Snapshot != "" {
+			meta, err = snapshotMetadata(m.profilesDir, profile, snap, save)
+		} else {
+			meta, err = parseMetadata(filepath.Join(base, save))
+			if c := m.integrity.check(filepath.Join(base, save)); !c.OK {
+				problems = append(problems, SaveProblem{Name: save, Reason: c.Reason})
+			}
+		}
+		if err == nil {
+			ms.Metadata = &meta
+			ms.QuestTitle, _ = quests.lookup(meta.TrackedQuestEntry)
+		}
+		e.manifest.Saves = append(e.manifest.Saves, ms)
+	}
+	sort.Slice(e.manifest.Saves, func(i, j int) bool { return e.manifest.Saves[i].Name < e.manifest.Saves[j].Name })
+	if len(problems) > 0 && !opts.Force {
+		sort.Slice(problems, func(i, j int) bool { return problems[i].Name < problems[j].Name })
+		return nil, &DamagedError{Msg: "profile contains damaged saves", Problems: problems}
+	}
+
+	if opts.Sign {
+		// Only zip archives can be verified on import.
+		if e.format != FormatZip {
+			return nil, fmt.Errorf("%w: only zip exports can be signed", ErrInvalid)
+		}
+		var err error
+		if e.key, err = m.signingKey(); err != nil {
+			return nil, fmt.Errorf("signing key: %w", err)
+		}
+	}
+	if opts.IncludeSnapshots && opts.Snapshot == "" {
+		for _, s := range listSnapshots(m.profilesDir, profile) {
+			entries, err := e.snapshotEntries(s.ID)
+			if err != nil {
+				return nil, err
+			}
+			e.entries = append(e.entries, entries...)
+		}
+	}
+	return e, nil
+}
+
+func (e *Export) fileEntry(base string, snap Snapshot, f SnapshotFile) exportEntry {
+	entry := exportEntry{name: e.profile + "/" + f.Path, size: f.Size, mod: snap.Created}
+	if snap.ID != "" {
+		entry.write = func(w io.Writer) error {
+			return writeSnapshotFile(w, e.m.profilesDir, e.profile, snap, f)
+		}
+		return entry
+	}
+	full := filepath.Join(base, filepath.FromSlash(f.Path))
+	if info, err := os.Stat(full); err == nil {
+		entry.mod = info.ModTime()
+	}
+	entry.write = func(w io.Writer) error {
+		in, err := os.Open(full)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		// The size was fixed when the export was prepared; tar needs exactly that.
+		n, err := io.Copy(w, io.LimitReader(in, f.Size))
+		if err == nil && n != f.Size {
+			err = fmt.Errorf("%s changed during export", f.Path)
+		}
+		return err
+	}
+	return entry
+}
+
+// snapshotEntries exports snapshot id as .snapshots/<profile>/<id>/, its
+// manifest (without store references) next to a files/ folder.
+func (e *Export) snapshotEntries(id string) ([]exportEntry, error) {
+	snap, err := loadSnapshot(e.m.profilesDir, e.profile, id)
+	if err != nil {
+		return nil, err
+	}
+	dir := snapshotsDirName + "/" + e.profile + "/" + snap.ID + "/"
+	meta := snap
+	meta.Files = make([]SnapshotFile, len(snap.Files))
+	for i, f := range snap.Files {
+		f.Chunks = nil
+		meta.Files[i] = f
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	entries := []exportEntry{bytesEntry(dir+"snapshot.json", data, snap.Created)}
+	for _, f := range snap.Files {
+		f := f
+		entries = append(entries, exportEntry{name: dir + "files/" + f.Path, size: f.Size, mod: snap.Created, write: func(w io.Writer) error {
+			return writeSnapshotFile(w, e.m.profilesDir, e.profile, snap, f)
+		}})
+	}
+	return entries, nil
+}
+
+func bytesEntry(name string, data []byte, mod time.Time) exportEntry {
+	return exportEntry{name: name, size: int64(len(data)), mod: mod, write: func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}}
+}
+
+// snapshotMetadata decodes a save's metadata file as stored in a snapshot.
+func snapshotMetadata(profilesDir, profile string, snap Snapshot, save string) (SaveMetadata, error) {
+	for _, f := range snap.Files {
+		if saveOf(f.Path) == save && isMetadataName(path.Base(f.Path)) {
+			var buf bytes.Buffer
+			if err := writeSnapshotFile(&buf, profilesDir, profile, snap, f); err != nil {
+				return SaveMetadata{}, err
+			}
+			return decodeMetadata(buf.Bytes(), path.Base(f.Path))
+		}
+	}
+	return SaveMetadata{}, fmt.Errorf("no metadata file in %s", save)
+}
+
+// Filename is the suggested name for the archive.
+func (e *Export) Filename() string {
+	name := e.profile
+	if e.manifest.Snapshot != "" {
+		name += "_" + e.manifest.Snapshot
+	}
+	return name + "." + e.format
+}
+
+func (e *Export) ContentType() string {
+	if e.format == FormatTarZst {
+		return "application/zstd"
+	}
+	return "application/zip"
+}
+
+// Stream writes the archive to w as it is read from disk, followed by the
+// manifest with the checksums computed on the way and, when signing, its
+// signature.
+func (e *Export) Stream(w io.Writer) error {
+	aw, err := newArchiveWriter(e.format, w)
+	if err != nil {
+		return err
+	}
+	j := e.m.startJob("export", e.profile)
+	manifest := e.manifest
+	for i, entry := range e.entries {
+		h := sha256.New()
+		err = aw.add(entry.name, entry.size, entry.mod, func(dst io.Writer) error {
+			return entry.write(io.MultiWriter(dst, h))
+		})
+		if err != nil {
+			aw.close()
+			j.finish(err)
+			return fmt.Errorf("%s: %w", entry.name, err)
+		}
+		manifest.Files = append(manifest.Files, ManifestFile{Path: entry.name, Size: entry.size, SHA256: hex.EncodeToString(h.Sum(nil))})
+		j.progress(i+1, len(e.entries))
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		m := bytesEntry(manifestName, data, manifest.Created)
+		err = aw.add(m.name, m.size, m.mod, m.write)
+	}
+	if err == nil && e.key != nil {
+		var sig []byte
+		if sig, err = signManifest(e.key, data); err == nil {
+			s := bytesEntry(signatureName, sig, manifest.Created)
+			err = aw.add(s.name, s.size, s.mod, s.write)
+		}
+	}
+	if cerr := aw.close(); err == nil {
+		err = cerr
+	}
+	j.finish(err)
+	return err
+}
+
+// archiveWriter is the part of zip and tar writing an export needs.
+type archiveWriter interface {
+	add(name string, size int64, mod time.Time, write func(io.Writer) error) error
+	close() error
+}
+
+func newArchiveWriter(format string, w io.Writer) (archiveWriter, error) {
+	if format == FormatTarZst {
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &tarZstWriter{zw: zw, tw: tar.NewWriter(zw)}, nil
+	}
+	return &zipWriter{zw: zip.NewWriter(w)}, nil
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipWriter) add(name string, size int64, mod time.Time, write func(io.Writer) error) error {
+	w, err := z.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: mod})
+	if err != nil {
+		return err
+	}
+	return write(w)
+}
+
+func (z *zipWriter) close() error { return z.zw.Close() }
+
+type tarZstWriter struct {
+	zw *zstd.Encoder
+	tw *tar.Writer
+}
+
+func (t *tarZstWriter) add(name string, size int64, mod time.Time, write func(io.Writer) error) error {
+	hdr := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: mod, Typeflag: tar.TypeReg}
+	if err := t.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	return write(t.tw)
+}
+
+func (t *tarZstWriter) close() error {
+	err := t.tw.Close()
+	if cerr := t.zw.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+)
+
+func (m *Manager) importFromGamePath(profile string, progress progressFunc) error {
+	if m.gameSavePath == "" {
+		return ErrNoGamePath
+	}
+	src := m.gameSavePath
+	dest := filepath.Join(m.profilesDir, profile)
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for i, e := range entries {
+		srcPath := filepath.Join(src, e.Name())
+		destPath := filepath.Join(dest, e.Name())
+		if err := copyDir(srcPath, destPath); err != nil {
+			return err
+		}
+		progress.report(i+1, len(entries))
+	}
+	return nil
+}
+
+func copyDir(src, dest string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return copyFile(src, dest)
+	}
+	if err := os.MkdirAll(dest, info.Mode()); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := copyDir(filepath.Join(src, e.Name()), filepath.Join(dest, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dest string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dest, data, 0o644)
+}
@@ -0,0 +1,351 @@
+package core
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxImportSize bounds the unpacked size of an imported archive so a
+// malicious zip cannot fill the disk.
+const maxImportSize = 16 << 30
+
+// Ways to resolve a save that already exists in the target profile.
+const (
+	CollisionRename  = "rename"
+	CollisionSkip    = "skip"
+	CollisionReplace = "replace"
+)
+
+type ImportOptions struct {
+	// Profile to import into. Empty means the profile folder named in the
+	// archive, or the archive's file name.
+	Profile string
+	// ArchiveName is the uploaded file name, used when nothing better names
+	// the profile or a save stored at the archive root.
+	ArchiveName string
+	// Merge allows importing into an existing profile.
+	Merge bool
+	// Collision is one of the Collision constants; empty means rename.
+	// Replace is refused for the active profile while the game runs.
+	Collision string
+}
+
+type ImportedSave struct {
+	Source string `json:"source"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+type ImportResult struct {
+	Profile string         `json:"profile"`
+	Created bool           `json:"created"`
+	Saves   []ImportedSave `json:"saves"`
+	Ignored []string       `json:"ignored"`
+	// Verification is what the archive's manifest vouched for.
+	Verification ArchiveVerification `json:"verification"`
+}
+
+// archiveSave is a save folder found in an archive: its folder path inside
+// the archive and the files below it.
+type archiveSave struct {
+	dir   string
+	name  string
+	files []*zip.File
+}
+
+// ImportArchive unpacks save folders from a zip, such as one made by
+// NewExport, into a profile. Any folder holding a sav.dat or metadata
+// file is taken as a save; everything else is reported as ignored. An archive
+// with a manifest must match it, and a signed one its signature, before
+// anything is unpacked. Saves are unpacked into a staging folder first so a
+// failed import leaves the profile untouched.
+func (m *Manager) ImportArchive(r io.ReaderAt, size int64, opts ImportOptions) (ImportResult, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("%w: not a zip archive: %v", ErrInvalid, err)
+	}
+	saves, rest, err := scanArchive(zr.File)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	verification, err := m.verifyArchive(zr.File)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	if len(saves) == 0 {
+		return ImportResult{}, fmt.Errorf("%w: no save folders found in archive", ErrInvalid)
+	}
+	profile := SanitizeName(opts.Profile)
+	if profile == "" {
+		profile = archiveProfileName(saves, opts.ArchiveName)
+	}
+	base := filepath.Join(m.profilesDir, profile)
+	res := ImportResult{Profile: profile, Created: !dirExists(base), Saves: []ImportedSave{}, Ignored: []string{}, Verification: verification}
+	if !res.Created && !opts.Merge {
+		return ImportResult{}, fmt.Errorf("profile %s %w", profile, ErrExists)
+	}
+	switch opts.Collision {
+	case "":
+		opts.Collision = CollisionRename
+	case CollisionRename, CollisionSkip, CollisionReplace:
+	default:
+		return ImportResult{}, fmt.Errorf("%w: unknown collision mode %q", ErrInvalid, opts.Collision)
+	}
+	// Replacing trashes saves, which the game may be using.
+	if opts.Collision == CollisionReplace && m.running.Load() && m.ActiveProfile() == profile {
+		return ImportResult{}, fmt.Errorf("cannot replace saves of the active profile while %w", ErrGameRunning)
+	}
+
+	used := map[string]bool{}
+	for _, s := range saves {
+		name := SanitizeName(path.Base(s.dir))
+		if s.dir == "." {
+			name = SanitizeName(strings.TrimSuffix(filepath.Base(opts.ArchiveName), filepath.Ext(opts.ArchiveName)))
+		}
+		if name == "" {
+			name = "ImportedSave"
+		}
+		s.name = name
+		for i := 2; used[s.name]; i++ {
+			s.name = fmt.Sprintf("%s_%d", name, i)
+		}
+		used[s.name] = true
+	}
+
+	staging, err := os.MkdirTemp(m.profilesDir, ".import_")
+	if err != nil {
+		return ImportResult{}, err
+	}
+	defer os.RemoveAll(staging)
+	j := m.startJob("import", profile)
+	total := 0
+	for _, s := range saves {
+		total += len(s.files)
+	}
+	done := 0
+	for _, s := range saves {
+		for _, f := range s.files {
+			rel := archivePath(f.Name)
+			if s.dir != "." {
+				rel = strings.TrimPrefix(rel, s.dir+"/")
+			}
+			if err := extractFile(f, filepath.Join(staging, s.name, filepath.FromSlash(rel))); err != nil {
+				j.finish(err)
+				return ImportResult{}, err
+			}
+			done++
+			j.progress(done, total)
+		}
+	}
+
+	if err := os.MkdirAll(base, 0o755); err != nil {
+		j.finish(err)
+		return ImportResult{}, err
+	}
+	stamp := time.Now().Format("20060102_150405")
+	for _, s := range saves {
+		name := s.name
+		item := ImportedSave{Source: s.dir, Name: name, Action: "added"}
+		dest := filepath.Join(base, name)
+		if _, err := os.Stat(dest); err == nil {
+			switch opts.Collision {
+			case CollisionSkip:
+				item.Action = "skipped"
+				res.Saves = append(res.Saves, item)
+				continue
+			case CollisionReplace:
+				if _, err := m.trashSave(profile, name); err != nil {
+					j.finish(err)
+					return res, err
+				}
+				item.Action = "replaced"
+			default:
+				item.Name = name + "_import_" + stamp
+				item.Action = "renamed"
+				dest = filepath.Join(base, item.Name)
+			}
+		}
+		if err := os.Rename(filepath.Join(staging, name), dest); err != nil {
+			j.finish(err)
+			return res, err
+		}
+		m.integrity.forget(dest)
+		res.Saves = append(res.Saves, item)
+	}
+	for _, f := range rest {
+		name := archivePath(f.Name)
+		if res.Verification.Manifest && (name == manifestName || name == signatureName) {
+			continue
+		}
+		// Carry the profile's metadata and save annotations along when the
+		// archive creates the profile.
+		if b := path.Base(name); res.Created && isProfileFile(b) && path.Dir(path.Dir(name)) == "." {
+			if err := extractFile(f, filepath.Join(base, b)); err == nil {
+				continue
+			}
+		}
+		res.Ignored = append(res.Ignored, name)
+	}
+	if err := migrateProfileMeta(m.profilesDir, profile); err != nil {
+		log.Printf("import %s: %v", profile, err)
+	}
+	j.finish(nil)
+	m.watcher.refresh(base)
+	return res, nil
+}
+
+// scanArchive checks every entry for a safe path and groups the files into
+// save folders. Files outside any save folder are returned as rest.
+func scanArchive(files []*zip.File) ([]*archiveSave, []*zip.File, error) {
+	var total uint64
+	dirs := map[string]bool{}
+	for _, f := range files {
+		name := archivePath(f.Name)
+		if !safeArchivePath(name) {
+			return nil, nil, fmt.Errorf("%w: unsafe path in archive: %s", ErrInvalid, f.Name)
+		}
+		total += f.UncompressedSize64
+		if total > maxImportSize {
+			return nil, nil, fmt.Errorf("%w: archive unpacks to more than %d GiB", ErrInvalid, maxImportSize>>30)
+		}
+		base := path.Base(name)
+		// Snapshots carried in an export hold copies of saves, not saves.
+		if strings.HasPrefix(name, snapshotsDirName+"/") {
+			continue
+		}
+		if !f.FileInfo().IsDir() && (base == "sav.dat" || isMetadataName(base)) {
+			dirs[path.Dir(name)] = true
+		}
+	}
+	var rest []*zip.File
+	// A zip of a single save's files has them at the root.
+	if dirs["."] && len(dirs) == 1 {
+		root := &archiveSave{dir: "."}
+		for _, f := range files {
+			if !f.FileInfo().IsDir() {
+				root.files = append(root.files, f)
+			}
+		}
+		return []*archiveSave{root}, rest, nil
+	}
+	// A folder nested inside another save belongs to that save.
+	var saveDirs []string
+	for d := range dirs {
+		if d != "." {
+			saveDirs = append(saveDirs, d)
+		}
+	}
+	sort.Slice(saveDirs, func(i, j int) bool {
+		if len(saveDirs[i]) != len(saveDirs[j]) {
+			return len(saveDirs[i]) < len(saveDirs[j])
+		}
+		return saveDirs[i] < saveDirs[j]
+	})
+	bySave := map[string]*archiveSave{}
+	var saves []*archiveSave
+	for _, d := range saveDirs {
+		if saveOwning(bySave, d) == nil {
+			s := &archiveSave{dir: d}
+			bySave[d] = s
+			saves = append(saves, s)
+		}
+	}
+	for _, f := range files {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if s := saveOwning(bySave, archivePath(f.Name)); s != nil {
+			s.files = append(s.files, f)
+		} else {
+			rest = append(rest, f)
+		}
+	}
+	return saves, rest, nil
+}
+
+// saveOwning returns the save whose folder contains name, if any.
+func saveOwning(bySave map[string]*archiveSave, name string) *archiveSave {
+	for d := path.Dir(name); d != "."; d = path.Dir(d) {
+		if s, ok := bySave[d]; ok {
+			return s
+		}
+	}
+	return nil
+}
+
+// archivePath normalises an entry name; zips made on Windows may use
+// backslashes.
+func archivePath(name string) string {
+	return strings.TrimSuffix(strings.ReplaceAll(name, `\`, "/"), "/")
+}
+
+func safeArchivePath(name string) bool {
+	if name == "" || strings.HasPrefix(name, "/") || strings.Contains(name, ":") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return path.Clean(name) == name
+}
+
+func isMetadataName(name string) bool {
+	return strings.HasPrefix(name, "metadata.") && strings.HasSuffix(name, ".json")
+}
+
+// archiveProfileName picks a profile name for an import: the single folder all
+// saves sit in, as in an exported profile, or else the archive's name.
+func archiveProfileName(saves []*archiveSave, archiveName string) string {
+	parent := path.Dir(saves[0].dir)
+	for _, s := range saves[1:] {
+		if path.Dir(s.dir) != parent {
+			parent = "."
+			break
+		}
+	}
+	if name := SanitizeName(path.Base(parent)); parent != "." && name != "" {
+		return name
+	}
+	if name := SanitizeName(strings.TrimSuffix(filepath.Base(archiveName), filepath.Ext(archiveName))); name != "" {
+		return name
+	}
+	return "Imported"
+}
+
+// extractFile writes one archive entry to dest, refusing entries that unpack
+// to more than they declare.
+func extractFile(f *zip.File, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	in, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.Name, err)
+	}
+	defer in.Close()
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, io.LimitReader(in, int64(f.UncompressedSize64)+1))
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && uint64(n) != f.UncompressedSize64 {
+		err = fmt.Errorf("%w: %s does not match its declared size", ErrInvalid, f.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.Name, err)
+	}
+	return nil
+}
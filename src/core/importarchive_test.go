@@ -0,0 +1,191 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"hash/crc32"
+	"path/filepath"
+	"testing"
+)
+
+// testZip builds an in-memory zip with add and returns its entries.
+func testZip(t *testing.T, add func(zw *zip.Writer)) []*zip.File {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	add(zw)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zr.File
+}
+
+// zipFiles writes each name with its content, in order.
+func zipFiles(t *testing.T, files ...string) func(zw *zip.Writer) {
+	t.Helper()
+	if len(files)%2 != 0 {
+		t.Fatal("zipFiles wants name, content pairs")
+	}
+	return func(zw *zip.Writer) {
+		for i := 0; i < len(files); i += 2 {
+			w, err := zw.Create(files[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write([]byte(files[i+1])); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// rawEntry stores data uncompressed under a header that declares size.
+func rawEntry(t *testing.T, zw *zip.Writer, name string, data []byte, size uint64) {
+	t.Helper()
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		CRC32:              crc32.ChecksumIEEE(data),
+		CompressedSize64:   uint64(len(data)),
+		UncompressedSize64: size,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSafeArchivePath(t *testing.T) {
+	tests := []struct {
+		name string
+		safe bool
+	}{
+		{"P/ManualSave-0/sav.dat", true},
+		{"sav.dat", true},
+		{"P/ManualSave-0/", true},
+		{"..", false},
+		{"../sav.dat", false},
+		{"P/../../sav.dat", false},
+		{"/etc/passwd", false},
+		{"/", false},
+		{"C:", false},
+		{"C:/Windows/sav.dat", false},
+		{`C:\Windows\sav.dat`, false},
+		{`..\sav.dat`, false},
+		{`P\..\..\sav.dat`, false},
+		{`\sav.dat`, false},
+		{"P//sav.dat", false},
+		{"P/./sav.dat", false},
+		{"./sav.dat", false},
+		{"P/ManualSave-0//", false},
+	}
+	for _, tt := range tests {
+		if got := safeArchivePath(archivePath(tt.name)); got != tt.safe {
+			t.Errorf("safeArchivePath(archivePath(%q)) = %v, want %v", tt.name, got, tt.safe)
+		}
+	}
+}
+
+func TestExtractFileDeclaredSize(t *testing.T) {
+	data := []byte("0123456789")
+	files := testZip(t, func(zw *zip.Writer) {
+		rawEntry(t, zw, "exact", data, uint64(len(data)))
+		rawEntry(t, zw, "understated", data, 4)
+		rawEntry(t, zw, "overstated", data, 64)
+	})
+	dir := t.TempDir()
+	if err := extractFile(files[0], filepath.Join(dir, "exact")); err != nil {
+		t.Fatalf("exact size: %v", err)
+	}
+	for _, f := range files[1:] {
+		if err := extractFile(f, filepath.Join(dir, f.Name)); err == nil {
+			t.Errorf("%s: extracted although it does not match its declared size", f.Name)
+		}
+	}
+}
+
+func TestScanArchiveMaxImportSize(t *testing.T) {
+	// Two entries that each claim just over half the limit; only the
+	// headers are looked at, so they hold next to nothing.
+	half := uint64(maxImportSize)/2 + 1
+	files := testZip(t, func(zw *zip.Writer) {
+		rawEntry(t, zw, "A/sav.dat", []byte("a"), half)
+		rawEntry(t, zw, "B/sav.dat", []byte("b"), half)
+	})
+	if _, _, err := scanArchive(files[:1]); err != nil {
+		t.Fatalf("one entry under the limit: %v", err)
+	}
+	if _, _, err := scanArchive(files); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("err = %v, want ErrInvalid", err)
+	}
+}
+
+func TestScanArchiveUnsafePath(t *testing.T) {
+	files := testZip(t, zipFiles(t, "P/ManualSave-0/sav.dat", "x", `P\..\..\evil.dll`, "x"))
+	if _, _, err := scanArchive(files); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("err = %v, want ErrInvalid", err)
+	}
+}
+
+func TestScanArchiveSingleSaveAtRoot(t *testing.T) {
+	files := testZip(t, func(zw *zip.Writer) {
+		if _, err := zw.Create("shots/"); err != nil {
+			t.Fatal(err)
+		}
+		zipFiles(t, "sav.dat", "x", "metadata.9.json", "{}", "shots/screenshot.png", "x")(zw)
+	})
+	saves, rest, err := scanArchive(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saves) != 1 || saves[0].dir != "." || len(saves[0].files) != 3 {
+		t.Fatalf("saves = %+v, want one root save with 3 files", saves)
+	}
+	if len(rest) != 0 {
+		t.Errorf("rest = %v, want none", entryNames(rest))
+	}
+}
+
+func TestScanArchiveNestedSaves(t *testing.T) {
+	files := testZip(t, zipFiles(t,
+		"P/ManualSave-1/sav.dat", "x",
+		"P/ManualSave-1/metadata.9.json", "{}",
+		"P/ManualSave-1/extra/sav.dat", "x",
+		"P/AutoSave-0/metadata.9.json", "{}",
+		"P/profile.json", "{}",
+		"sav.dat", "x",
+		snapshotsDirName+"/P/1/sav.dat", "x",
+	))
+	saves, rest, err := scanArchive(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]string{}
+	for _, s := range saves {
+		got[s.dir] = entryNames(s.files)
+	}
+	if len(got) != 2 || len(got["P/ManualSave-1"]) != 3 || len(got["P/AutoSave-0"]) != 1 {
+		t.Fatalf("saves = %v, want ManualSave-1 with its nested folder and AutoSave-0", got)
+	}
+	if names := entryNames(rest); len(names) != 3 || names[0] != "P/profile.json" || names[1] != "sav.dat" {
+		t.Errorf("rest = %v, want the profile file, the stray root save and the snapshot", names)
+	}
+	if name := archiveProfileName(saves, "upload.zip"); name != "P" {
+		t.Errorf("archiveProfileName = %q, want P", name)
+	}
+}
+
+func entryNames(files []*zip.File) []string {
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	return names
+}
@@ -0,0 +1,243 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const switchJournalFile = ".switch_journal.json"
+
+const (
+	stepRenamed  = "renamed"
+	stepUnlinked = "unlinked"
+	stepMoved    = "moved"
+	stepLinked   = "linked"
+)
+
+// switchJournal is the intent record written before a profile switch touches the
+// game save path. If the process dies mid-switch the record survives and
+// recoverSwitch puts the previous link or folder back on the next start.
+// RenameFrom is set when the switch follows renaming the active profile's
+// folder from RenameFrom to Target, so the rename is undone with it.
+type switchJournal struct {
+	LinkPath   string    `json:"linkPath"`
+	Target     string    `json:"target"`
+	RenameFrom string    `json:"renameFrom,omitempty"`
+	PrevLink   string    `json:"prevLink,omitempty"`
+	Backup     string    `json:"backup,omitempty"`
+	Steps      []string  `json:"steps"`
+	Started    time.Time `json:"started"`
+
+	path string
+}
+
+type SwitchRecovery struct {
+	Time     time.Time `json:"time"`
+	Target   string    `json:"target"`
+	Steps    []string  `json:"steps"`
+	Restored string    `json:"restored"`
+	Error    string    `json:"error,omitempty"`
+	// Pending is set while the journal is kept because rolling back failed;
+	// no switch can run until RecoverSwitch resolves it.
+	Pending bool `json:"pending"`
+	// Cleared is set when the journal was set aside without a rollback,
+	// leaving the save folder as it was.
+	Cleared bool `json:"cleared,omitempty"`
+}
+
+func switchJunction(l linker, profilesDir, linkPath, target string) error {
+	return renameAndSwitch(l, profilesDir, linkPath, "", target)
+}
+
+// renameAndSwitch renames the folder from to target, when from is set, and
+// points linkPath at target under one journal entry, so that a failure or
+// crash between the two rolls both back.
+func renameAndSwitch(l linker, profilesDir, linkPath, from, target string) error {
+	// Another CyberSaver process may be switching; wait for it rather than
+	// finding its journal and refusing.
+	defer lockProfilesFile(profilesDir, journalLockFile, true)()
+	j := &switchJournal{
+		LinkPath:   linkPath,
+		Target:     target,
+		RenameFrom: from,
+		Steps:      []string{},
+		Started:    time.Now(),
+		path:       filepath.Join(profilesDir, switchJournalFile),
+	}
+	if _, err := os.Stat(j.path); err == nil {
+		return ErrSwitchPending
+	}
+	if info, err := os.Lstat(linkPath); err == nil {
+		switch {
+		case l.IsLink(linkPath):
+			prev, err := l.Target(linkPath)
+			if err != nil {
+				return err
+			}
+			j.PrevLink = prev
+		case info.IsDir():
+			j.Backup = linkPath + "_backup_" + time.Now().Format("20060102_150405")
+		default:
+			return fmt.Errorf("existing path is not a folder")
+		}
+	}
+	if from == "" {
+		if err := os.MkdirAll(target, 0o755); err != nil {
+			return err
+		}
+	}
+	if err := j.save(); err != nil {
+		return fmt.Errorf("could not write switch journal: %w", err)
+	}
+	if err := j.run(l); err != nil {
+		restored, rerr := j.rollback(l)
+		if rerr != nil {
+			return fmt.Errorf("%v; rollback failed: %v: %w", err, rerr, ErrSwitchPending)
+		}
+		_ = os.Remove(j.path)
+		return fmt.Errorf("%v; restored %s", err, restored)
+	}
+	return os.Remove(j.path)
+}
+
+func (j *switchJournal) run(l linker) error {
+	if j.RenameFrom != "" {
+		if err := os.Rename(j.RenameFrom, j.Target); err != nil {
+			return err
+		}
+		if err := j.mark(stepRenamed); err != nil {
+			return err
+		}
+	}
+	switch {
+	case j.PrevLink != "":
+		if err := l.Remove(j.LinkPath); err != nil {
+			return err
+		}
+		if err := j.mark(stepUnlinked); err != nil {
+			return err
+		}
+	case j.Backup != "":
+		if err := os.Rename(j.LinkPath, j.Backup); err != nil {
+			return fmt.Errorf("existing folder could not be moved: %w", err)
+		}
+		if err := j.mark(stepMoved); err != nil {
+			return err
+		}
+	}
+	if err := l.Create(j.LinkPath, j.Target); err != nil {
+		return err
+	}
+	return j.mark(stepLinked)
+}
+
+// rollback inspects the filesystem rather than trusting Steps, since a crash can
+// land between performing a step and recording it.
+func (j *switchJournal) rollback(l linker) (string, error) {
+	if j.RenameFrom != "" && !dirExists(j.RenameFrom) && dirExists(j.Target) {
+		if err := os.Rename(j.Target, j.RenameFrom); err != nil {
+			return "", fmt.Errorf("renaming %s back: %w", filepath.Base(j.Target), err)
+		}
+	}
+	if l.IsLink(j.LinkPath) {
+		cur, err := l.Target(j.LinkPath)
+		if err == nil && samePath(cur, j.Target) && (j.PrevLink == "" || !samePath(cur, j.PrevLink)) {
+			if err := l.Remove(j.LinkPath); err != nil {
+				return "", err
+			}
+		}
+	}
+	switch {
+	case j.PrevLink != "":
+		if !l.IsLink(j.LinkPath) {
+			if err := l.Create(j.LinkPath, j.PrevLink); err != nil {
+				return "", err
+			}
+		}
+		return "link to " + j.PrevLink, nil
+	case j.Backup != "":
+		if _, err := os.Lstat(j.Backup); err == nil {
+			if _, err := os.Lstat(j.LinkPath); err == nil {
+				return "", fmt.Errorf("%s is occupied, original folder left at %s", j.LinkPath, j.Backup)
+			}
+			if err := os.Rename(j.Backup, j.LinkPath); err != nil {
+				return "", err
+			}
+		}
+		return "folder " + j.LinkPath, nil
+	default:
+		return "empty save path", nil
+	}
+}
+
+func (j *switchJournal) mark(step string) error {
+	j.Steps = append(j.Steps, step)
+	return j.save()
+}
+
+func (j *switchJournal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// recoverSwitch rolls back a switch left unfinished by a crash. It returns nil
+// when there was nothing to recover.
+func recoverSwitch(l linker, profilesDir string) *SwitchRecovery {
+	// The lock keeps a journal another process is still working on from
+	// being taken for a crashed one.
+	defer lockProfilesFile(profilesDir, journalLockFile, true)()
+	path := filepath.Join(profilesDir, switchJournalFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	rec := &SwitchRecovery{Time: time.Now()}
+	var j switchJournal
+	if err == nil {
+		err = json.Unmarshal(data, &j)
+	}
+	if err != nil {
+		rec.Error = "unreadable switch journal: " + err.Error()
+		_ = os.Rename(path, path+".bad")
+		log.Printf("switch recovery: %s", rec.Error)
+		return rec
+	}
+	j.path = path
+	rec.Target = j.Target
+	rec.Steps = j.Steps
+	restored, err := j.rollback(l)
+	if err != nil {
+		rec.Error = err.Error()
+		rec.Pending = true
+		log.Printf("switch recovery failed: %v", err)
+		return rec
+	}
+	rec.Restored = restored
+	_ = os.Remove(path)
+	log.Printf("switch recovery: interrupted switch to %s rolled back, restored %s", j.Target, restored)
+	return rec
+}
+
+// clearSwitchJournal sets the journal aside as .cleared so switching works
+// again without rolling back.
+func clearSwitchJournal(profilesDir string) error {
+	defer lockProfilesFile(profilesDir, journalLockFile, true)()
+	path := filepath.Join(profilesDir, switchJournalFile)
+	if err := os.Rename(path, path+".cleared"); err != nil {
+		return err
+	}
+	log.Printf("switch recovery: journal cleared without rollback")
+	return nil
+}
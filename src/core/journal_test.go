@@ -0,0 +1,270 @@
+//go:build !windows
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// failingLinker is a symlinkLinker whose first fail calls to Create fail.
+type failingLinker struct {
+	symlinkLinker
+	fail int
+}
+
+func (f *failingLinker) Create(linkPath, target string) error {
+	if f.fail > 0 {
+		f.fail--
+		return errors.New("create failed")
+	}
+	return f.symlinkLinker.Create(linkPath, target)
+}
+
+type switchFixture struct {
+	profilesDir, link, a, b string
+}
+
+func newSwitchFixture(t *testing.T) switchFixture {
+	t.Helper()
+	dir := t.TempDir()
+	f := switchFixture{
+		profilesDir: filepath.Join(dir, "profiles"),
+		link:        filepath.Join(dir, "saves"),
+	}
+	f.a = filepath.Join(f.profilesDir, "A")
+	f.b = filepath.Join(f.profilesDir, "B")
+	for _, d := range []string{f.a, f.b} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+func (f switchFixture) journal() string { return filepath.Join(f.profilesDir, switchJournalFile) }
+
+func assertLink(t *testing.T, l linker, link, want string) {
+	t.Helper()
+	got, err := l.Target(link)
+	if err != nil || !samePath(got, want) {
+		t.Fatalf("link points at %q (%v), want %q", got, err, want)
+	}
+}
+
+func assertNoJournal(t *testing.T, f switchFixture) {
+	t.Helper()
+	if _, err := os.Stat(f.journal()); !os.IsNotExist(err) {
+		t.Fatalf("journal left behind: %v", err)
+	}
+}
+
+func TestSwitchJunctionFresh(t *testing.T) {
+	f := newSwitchFixture(t)
+	l := symlinkLinker{}
+	if err := switchJunction(l, f.profilesDir, f.link, f.a); err != nil {
+		t.Fatal(err)
+	}
+	assertLink(t, l, f.link, f.a)
+	assertNoJournal(t, f)
+}
+
+func TestSwitchJunctionRelinks(t *testing.T) {
+	f := newSwitchFixture(t)
+	l := symlinkLinker{}
+	if err := switchJunction(l, f.profilesDir, f.link, f.a); err != nil {
+		t.Fatal(err)
+	}
+	if err := switchJunction(l, f.profilesDir, f.link, f.b); err != nil {
+		t.Fatal(err)
+	}
+	assertLink(t, l, f.link, f.b)
+	assertNoJournal(t, f)
+}
+
+func TestSwitchJunctionBacksUpFolder(t *testing.T) {
+	f := newSwitchFixture(t)
+	l := symlinkLinker{}
+	if err := os.MkdirAll(filepath.Join(f.link, "ManualSave-0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := switchJunction(l, f.profilesDir, f.link, f.a); err != nil {
+		t.Fatal(err)
+	}
+	assertLink(t, l, f.link, f.a)
+	backups, _ := filepath.Glob(f.link + "_backup_*")
+	if len(backups) != 1 || !dirExists(filepath.Join(backups[0], "ManualSave-0")) {
+		t.Fatalf("backups = %v, want the old folder moved aside", backups)
+	}
+	assertNoJournal(t, f)
+}
+
+func TestSwitchJunctionRollsBackLink(t *testing.T) {
+	f := newSwitchFixture(t)
+	sl := symlinkLinker{}
+	if err := switchJunction(sl, f.profilesDir, f.link, f.a); err != nil {
+		t.Fatal(err)
+	}
+	// Creating the new link fails; rollback recreates the old one.
+	if err := switchJunction(&failingLinker{fail: 1}, f.profilesDir, f.link, f.b); err == nil {
+		t.Fatal("switch succeeded")
+	}
+	assertLink(t, sl, f.link, f.a)
+	assertNoJournal(t, f)
+}
+
+func TestSwitchJunctionRollsBackFolder(t *testing.T) {
+	f := newSwitchFixture(t)
+	if err := os.MkdirAll(filepath.Join(f.link, "ManualSave-0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	l := &failingLinker{fail: 1}
+	if err := switchJunction(l, f.profilesDir, f.link, f.a); err == nil {
+		t.Fatal("switch succeeded")
+	}
+	if l.IsLink(f.link) || !dirExists(filepath.Join(f.link, "ManualSave-0")) {
+		t.Fatal("original folder not put back")
+	}
+	assertNoJournal(t, f)
+}
+
+func TestRecoverSwitchAfterCrash(t *testing.T) {
+	f := newSwitchFixture(t)
+	l := symlinkLinker{}
+	if err := l.Create(f.link, f.a); err != nil {
+		t.Fatal(err)
+	}
+	// Crash after the old link was removed and the new one created, before
+	// the journal was cleared.
+	if err := l.Remove(f.link); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Create(f.link, f.b); err != nil {
+		t.Fatal(err)
+	}
+	j := switchJournal{LinkPath: f.link, Target: f.b, PrevLink: f.a, Steps: []string{stepUnlinked}, Started: time.Now()}
+	data, _ := json.Marshal(j)
+	if err := os.WriteFile(f.journal(), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec := recoverSwitch(l, f.profilesDir)
+	if rec == nil || rec.Error != "" {
+		t.Fatalf("recovery = %+v", rec)
+	}
+	assertLink(t, l, f.link, f.a)
+	assertNoJournal(t, f)
+}
+
+func TestSwitchJunctionRefusesWhileJournalPending(t *testing.T) {
+	f := newSwitchFixture(t)
+	if err := os.WriteFile(f.journal(), []byte(`{}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := switchJunction(symlinkLinker{}, f.profilesDir, f.link, f.a); !errors.Is(err, ErrSwitchPending) {
+		t.Fatalf("switch over a pending journal: %v", err)
+	}
+}
+
+func TestRecoverSwitchFailureStaysPending(t *testing.T) {
+	f := newSwitchFixture(t)
+	// The original folder was moved aside, but something else now occupies
+	// the save path, so the rollback cannot put it back.
+	backup := f.link + "_backup_x"
+	for _, d := range []string{backup, f.link} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	j := switchJournal{LinkPath: f.link, Target: f.a, Backup: backup, Steps: []string{stepMoved}, Started: time.Now()}
+	data, _ := json.Marshal(j)
+	if err := os.WriteFile(f.journal(), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec := recoverSwitch(symlinkLinker{}, f.profilesDir)
+	if rec == nil || rec.Error == "" || !rec.Pending {
+		t.Fatalf("recovery = %+v, want a pending failure", rec)
+	}
+	if _, err := os.Stat(f.journal()); err != nil {
+		t.Fatalf("journal dropped after a failed rollback: %v", err)
+	}
+	if err := clearSwitchJournal(f.profilesDir); err != nil {
+		t.Fatal(err)
+	}
+	assertNoJournal(t, f)
+	if err := switchJunction(symlinkLinker{}, f.profilesDir, f.link+"2", f.a); err != nil {
+		t.Fatalf("switch after clearing: %v", err)
+	}
+}
+
+func TestRecoverSwitchNothingToDo(t *testing.T) {
+	f := newSwitchFixture(t)
+	if rec := recoverSwitch(symlinkLinker{}, f.profilesDir); rec != nil {
+		t.Fatalf("recovery = %+v, want nil", rec)
+	}
+}
+
+func TestRenameAndSwitch(t *testing.T) {
+	f := newSwitchFixture(t)
+	l := symlinkLinker{}
+	if err := switchJunction(l, f.profilesDir, f.link, f.a); err != nil {
+		t.Fatal(err)
+	}
+	renamed := filepath.Join(f.profilesDir, "A2")
+	if err := renameAndSwitch(l, f.profilesDir, f.link, f.a, renamed); err != nil {
+		t.Fatal(err)
+	}
+	assertLink(t, l, f.link, renamed)
+	if dirExists(f.a) || !dirExists(renamed) {
+		t.Fatal("folder not renamed")
+	}
+	assertNoJournal(t, f)
+}
+
+func TestRenameAndSwitchRollsBackRename(t *testing.T) {
+	f := newSwitchFixture(t)
+	if err := switchJunction(symlinkLinker{}, f.profilesDir, f.link, f.a); err != nil {
+		t.Fatal(err)
+	}
+	renamed := filepath.Join(f.profilesDir, "A2")
+	if err := renameAndSwitch(&failingLinker{fail: 1}, f.profilesDir, f.link, f.a, renamed); err == nil {
+		t.Fatal("switch succeeded")
+	}
+	if !dirExists(f.a) || dirExists(renamed) {
+		t.Fatal("rename not undone")
+	}
+	assertLink(t, symlinkLinker{}, f.link, f.a)
+	assertNoJournal(t, f)
+}
+
+func TestRecoverSwitchUndoesRename(t *testing.T) {
+	f := newSwitchFixture(t)
+	l := symlinkLinker{}
+	renamed := filepath.Join(f.profilesDir, "A2")
+	// Crash after the folder was renamed and relinked, before the journal was
+	// cleared.
+	if err := os.Rename(f.a, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Create(f.link, renamed); err != nil {
+		t.Fatal(err)
+	}
+	j := switchJournal{LinkPath: f.link, Target: renamed, RenameFrom: f.a, PrevLink: f.a,
+		Steps: []string{stepRenamed, stepUnlinked, stepLinked}, Started: time.Now()}
+	data, _ := json.Marshal(j)
+	if err := os.WriteFile(f.journal(), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec := recoverSwitch(l, f.profilesDir)
+	if rec == nil || rec.Error != "" {
+		t.Fatalf("recovery = %+v", rec)
+	}
+	if !dirExists(f.a) || dirExists(renamed) {
+		t.Fatal("rename not undone")
+	}
+	assertLink(t, l, f.link, f.a)
+	assertNoJournal(t, f)
+}
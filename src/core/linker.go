@@ -0,0 +1,78 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// linker abstracts how the game save folder is pointed at a profile.
+// Windows uses directory junctions; everything else (Proton, Steam Deck) uses symlinks.
+type linker interface {
+	Create(linkPath, target string) error
+	Remove(linkPath string) error
+	Target(linkPath string) (string, error)
+	IsLink(linkPath string) bool
+}
+
+func newLinker() linker {
+	if runtime.GOOS == "windows" {
+		return junctionLinker{}
+	}
+	return symlinkLinker{}
+}
+
+type junctionLinker struct{}
+
+func (junctionLinker) Create(linkPath, target string) error {
+	cmd := exec.Command("cmd", "/C", "mklink", "/J", linkPath, target)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("mklink failed: %v (%s)", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (j junctionLinker) Remove(linkPath string) error {
+	if !j.IsLink(linkPath) {
+		return fmt.Errorf("%s is not a junction", linkPath)
+	}
+	return os.Remove(linkPath)
+}
+
+func (junctionLinker) Target(linkPath string) (string, error) {
+	return os.Readlink(linkPath)
+}
+
+func (junctionLinker) IsLink(linkPath string) bool {
+	info, err := os.Lstat(linkPath)
+	if err != nil {
+		return false
+	}
+	// Newer Go releases report mount points as irregular rather than symlinks.
+	return info.Mode()&(os.ModeSymlink|os.ModeIrregular) != 0
+}
+
+type symlinkLinker struct{}
+
+func (symlinkLinker) Create(linkPath, target string) error {
+	return os.Symlink(target, linkPath)
+}
+
+func (s symlinkLinker) Remove(linkPath string) error {
+	if !s.IsLink(linkPath) {
+		return fmt.Errorf("%s is not a symlink", linkPath)
+	}
+	return os.Remove(linkPath)
+}
+
+func (symlinkLinker) Target(linkPath string) (string, error) {
+	return os.Readlink(linkPath)
+}
+
+func (symlinkLinker) IsLink(linkPath string) bool {
+	info, err := os.Lstat(linkPath)
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
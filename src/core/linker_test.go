@@ -0,0 +1,63 @@
+//go:build !windows
+
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSymlinkLinker(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "profile")
+	if err := os.Mkdir(target, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "saves")
+	l := symlinkLinker{}
+
+	if l.IsLink(link) {
+		t.Fatal("IsLink on a missing path")
+	}
+	if err := l.Create(link, target); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !l.IsLink(link) {
+		t.Fatal("IsLink false after Create")
+	}
+	got, err := l.Target(link)
+	if err != nil || got != target {
+		t.Fatalf("Target = %q, %v; want %q", got, err, target)
+	}
+	if err := l.Create(link, target); err == nil {
+		t.Fatal("Create over an existing link succeeded")
+	}
+	if err := l.Remove(link); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Fatalf("link still there after Remove: %v", err)
+	}
+	if !dirExists(target) {
+		t.Fatal("Remove deleted the target")
+	}
+}
+
+func TestSymlinkLinkerRemoveRefusesFolders(t *testing.T) {
+	dir := t.TempDir()
+	folder := filepath.Join(dir, "saves")
+	if err := os.Mkdir(folder, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	l := symlinkLinker{}
+	if l.IsLink(folder) {
+		t.Fatal("IsLink true for a plain folder")
+	}
+	if err := l.Remove(folder); err == nil {
+		t.Fatal("Remove of a plain folder succeeded")
+	}
+	if !dirExists(folder) {
+		t.Fatal("folder removed")
+	}
+}
@@ -0,0 +1,36 @@
+package core
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// Lock files in the profiles folder. The tray app and a command-line run can
+// work on the same folder at once; these order them where the in-process
+// mutexes cannot. The OS releases a lock when its process dies.
+const (
+	journalLockFile = ".journal.lock"
+	metaLockFile    = ".meta.lock"
+	storeLockFile   = ".store.lock"
+)
+
+// lockProfilesFile blocks until it holds the named lock file in profilesDir,
+// shared or exclusive, and returns the function that releases it. When the
+// lock file cannot be used the caller goes ahead unlocked.
+func lockProfilesFile(profilesDir, name string, exclusive bool) func() {
+	f, err := os.OpenFile(filepath.Join(profilesDir, name), os.O_CREATE|os.O_RDWR, 0o644)
+	if err == nil {
+		if err = lockHandle(f, exclusive); err != nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		log.Printf("lock %s: %v", name, err)
+		return func() {}
+	}
+	return func() {
+		unlockHandle(f)
+		f.Close()
+	}
+}
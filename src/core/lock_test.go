@@ -0,0 +1,44 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLockProfilesFileExcludes(t *testing.T) {
+	dir := t.TempDir()
+	unlock := lockProfilesFile(dir, journalLockFile, true)
+	got := make(chan struct{})
+	go func() {
+		// A second handle stands in for another process.
+		defer lockProfilesFile(dir, journalLockFile, true)()
+		close(got)
+	}()
+	select {
+	case <-got:
+		t.Fatal("second exclusive lock taken while the first was held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-got:
+	case <-time.After(5 * time.Second):
+		t.Fatal("second lock not taken after release")
+	}
+}
+
+func TestLockProfilesFileShared(t *testing.T) {
+	dir := t.TempDir()
+	unlock := lockProfilesFile(dir, storeLockFile, false)
+	defer unlock()
+	done := make(chan struct{})
+	go func() {
+		defer lockProfilesFile(dir, storeLockFile, false)()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("shared locks excluded each other")
+	}
+}
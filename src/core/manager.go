@@ -0,0 +1,216 @@
+// Package core manages CyberSaver profiles: switching the Cyberpunk 2077 save
+// folder between them, reading and verifying saves, snapshots and backups.
+// The tray app, web UI and command line are thin frontends over Manager.
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// Options configures a Manager. An empty GameSavePath falls back to the
+// default Cyberpunk 2077 save folder.
+type Options struct {
+	GameSavePath string
+	ProfilesDir  string
+	Backup       BackupConfig
+	// TrashDays is how long deleted profiles and saves are kept; zero means
+	// DefaultTrashDays and a negative value keeps them until purged.
+	TrashDays int
+	// KeysDir holds the export signing key and the trusted keys. It must not
+	// be inside ProfilesDir, which the web UI serves; empty means a
+	// CyberSaver folder in the user's config directory.
+	KeysDir string
+}
+
+type Manager struct {
+	gameSavePath   string
+	gamePathExists bool
+	profilesDir    string
+	keysDir        string
+	linker         linker
+	recovery       atomic.Pointer[SwitchRecovery]
+	integrity      *integrityCache
+	search         *searchIndex
+	backups        *backupScheduler
+	watcher        *saveWatcher
+	events         *eventHub
+	jobs           *jobList
+	running        atomic.Bool
+	trashDays      atomic.Int32
+}
+
+// New opens the profiles folder, creating it if needed, finishes any profile
+// switch that was interrupted and moves old .note.txt files to profile.json.
+func New(opts Options) (*Manager, error) {
+	if opts.ProfilesDir == "" {
+		return nil, fmt.Errorf("%w: profiles folder not set", ErrInvalid)
+	}
+	m := &Manager{
+		profilesDir: opts.ProfilesDir,
+		keysDir:     opts.KeysDir,
+		linker:      newLinker(),
+		integrity:   newIntegrityCache(),
+		search:      newSearchIndex(),
+		events:      newEventHub(),
+		jobs:        &jobList{},
+	}
+	m.gameSavePath, m.gamePathExists = detectGameSavePath()
+	if opts.GameSavePath != "" {
+		m.gameSavePath = opts.GameSavePath
+		m.gamePathExists = dirExists(opts.GameSavePath)
+	}
+	if err := os.MkdirAll(m.profilesDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create profiles dir: %w", err)
+	}
+	if m.keysDir == "" {
+		if dir, err := os.UserConfigDir(); err == nil {
+			m.keysDir = filepath.Join(dir, "CyberSaver")
+		}
+	}
+	m.recovery.Store(recoverSwitch(m.linker, m.profilesDir))
+	m.migrateSigningKey()
+	migrateProfiles(m.profilesDir)
+	m.backups = newBackupScheduler(m, opts.Backup)
+	m.SetTrashDays(opts.TrashDays)
+	return m, nil
+}
+
+// Start runs the background work of a long-lived frontend: migrating old
+// snapshots, scheduled backups, emptying expired trash and the save watcher,
+// whose changes are published as "save" events.
+func (m *Manager) Start() {
+	go migrateSnapshots(m.profilesDir)
+	go m.backups.loop()
+	go m.trashLoop()
+	m.watcher = newSaveWatcher(m)
+	m.watcher.subscribe(func(ev SaveEvent) { m.events.publish("save", ev) })
+	go m.watcher.run()
+}
+
+// Subscribe returns a channel of events and a function that ends the
+// subscription. Slow subscribers miss events rather than block others.
+func (m *Manager) Subscribe() (<-chan Event, func()) {
+	return m.events.subscribe()
+}
+
+func (m *Manager) GameSavePath() string { return m.gameSavePath }
+
+// GamePathExists reports whether the game save folder is known and present.
+func (m *Manager) GamePathExists() bool {
+	return m.gamePathExists && dirExists(m.gameSavePath)
+}
+
+func (m *Manager) SetGameSavePath(path string) {
+	m.gameSavePath = path
+	m.gamePathExists = dirExists(path)
+	m.watcher.rootsChanged()
+}
+
+func (m *Manager) ProfilesDir() string { return m.profilesDir }
+
+// Recovery describes the last interrupted or failed switch that had to be
+// rolled back, if any. Pending is set while it still needs RecoverSwitch.
+func (m *Manager) Recovery() *SwitchRecovery { return m.recovery.Load() }
+
+// switchFailed records a switch whose rollback failed so Recovery reports it.
+func (m *Manager) switchFailed(target string, err error) {
+	if !errors.Is(err, ErrSwitchPending) {
+		return
+	}
+	if rec := m.recovery.Load(); rec != nil && rec.Pending {
+		return
+	}
+	m.recovery.Store(&SwitchRecovery{Time: time.Now(), Target: target, Steps: []string{}, Error: err.Error(), Pending: true})
+}
+
+// RecoverSwitch retries rolling back a switch whose rollback failed. With
+// force, a journal that still cannot be rolled back is set aside and the save
+// folder is left as it is, so that switching works again.
+func (m *Manager) RecoverSwitch(force bool) (*SwitchRecovery, error) {
+	if m.running.Load() {
+		return nil, fmt.Errorf("cannot recover while %w", ErrGameRunning)
+	}
+	rec := recoverSwitch(m.linker, m.profilesDir)
+	if rec == nil {
+		return nil, fmt.Errorf("interrupted switch %w", ErrNotFound)
+	}
+	if rec.Pending && force {
+		if err := clearSwitchJournal(m.profilesDir); err != nil {
+			return rec, err
+		}
+		rec.Pending, rec.Cleared = false, true
+	}
+	m.recovery.Store(rec)
+	m.watcher.rootsChanged()
+	return rec, nil
+}
+
+func (m *Manager) GameRunning() bool { return m.running.Load() }
+
+// SetGameRunning records whether Cyberpunk is running. Switches, imports and
+// restores are refused while it is; a change is published as a "game" event
+// and may trigger an automatic backup. When the game exits the active
+// profile's last-played time is updated.
+func (m *Manager) SetGameRunning(running bool) {
+	if m.running.Swap(running) == running {
+		return
+	}
+	if !running {
+		active := m.ActiveProfile()
+		m.touchLastPlayed(active)
+		// Prune only once the exit backup has a copy of what it would remove.
+		go func() {
+			m.backups.gameExited()
+			m.pruneAfterExit(active)
+		}()
+	}
+	m.events.publish("game", map[string]bool{"running": running})
+}
+
+// ActiveProfile returns the profile the game save folder points at, or "".
+func (m *Manager) ActiveProfile() string {
+	target, err := m.linker.Target(m.gameSavePath)
+	if err != nil {
+		return ""
+	}
+	for _, p := range profileNames(m.profilesDir) {
+		if samePath(target, filepath.Join(m.profilesDir, p)) {
+			return p
+		}
+	}
+	return ""
+}
+
+// ForeignLink returns where the game save folder points when it is a link
+// that does not lead into the profiles folder, or "".
+func (m *Manager) ForeignLink() string {
+	if !m.linker.IsLink(m.gameSavePath) {
+		return ""
+	}
+	target, err := m.linker.Target(m.gameSavePath)
+	if err != nil || pointsIntoProfiles(target, m.profilesDir) {
+		return ""
+	}
+	return target
+}
+
+// BackupGameSaves copies the game save folder next to itself with a
+// timestamped name and returns the copy's path.
+func (m *Manager) BackupGameSaves() (string, error) {
+	if !m.GamePathExists() {
+		return "", ErrNoGamePath
+	}
+	backupDir := filepath.Join(filepath.Dir(m.gameSavePath), "Cyberpunk 2077_backup_"+time.Now().Format("20060102_150405"))
+	return backupDir, copyDir(m.gameSavePath, backupDir)
+}
+
+// LookupQuest returns the quest title and objective text for a journal path
+// such as a save's trackedQuestEntry.
+func (m *Manager) LookupQuest(path string) (title, objective string) {
+	return quests.lookup(path)
+}
@@ -0,0 +1,355 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"cybersaver/savfile"
+)
+
+// Saves returns the saves in a profile, newest first, from the watcher's
+// index when the profile is watched and from disk otherwise.
+func (m *Manager) Saves(profile string) []SaveInfo {
+	profile = SanitizeName(profile)
+	saves := m.listSaves(profile)
+	annotateSaves(m.profilesDir, profile, saves)
+	return saves
+}
+
+func (m *Manager) listSaves(profile string) []SaveInfo {
+	base := filepath.Join(m.profilesDir, profile)
+	if m.watcher != nil {
+		if saves, ok := m.watcher.list(base); ok {
+			return saves
+		}
+	}
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return []SaveInfo{}
+	}
+	type saveWithTime struct {
+		SaveInfo
+		mod time.Time
+	}
+	var saves []saveWithTime
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if info, mod, ok := m.readSave(base, e.Name()); ok {
+			saves = append(saves, saveWithTime{SaveInfo: info, mod: mod})
+		}
+	}
+	sort.Slice(saves, func(i, j int) bool { return saves[i].mod.After(saves[j].mod) })
+	resp := make([]SaveInfo, 0, len(saves))
+	for _, sv := range saves {
+		resp = append(resp, sv.SaveInfo)
+	}
+	return resp
+}
+
+func (m *Manager) readSave(base, name string) (SaveInfo, time.Time, bool) {
+	savePath := filepath.Join(base, name)
+	info, err := os.Stat(savePath)
+	if err != nil || !info.IsDir() {
+		return SaveInfo{}, time.Time{}, false
+	}
+	meta := readMetadata(savePath)
+	check := m.integrity.check(savePath)
+	return SaveInfo{
+		Name:       name,
+		Modified:   info.ModTime().Format("2006-01-02 15:04:05"),
+		Type:       classifySave(name),
+		Screenshot: findScreenshot(savePath),
+		Playtime:   meta.Playtime,
+		Level:      meta.Level,
+		Quest:      meta.Quest,
+		QuestTitle: meta.QuestTitle,
+		Objective:  meta.Objective,
+		Corrupt:    !check.OK,
+		Problem:    check.Reason,
+		Tags:       []string{},
+	}, info.ModTime(), true
+}
+
+func classifySave(name string) string {
+	n := strings.ToLower(name)
+	switch {
+	case strings.Contains(n, "auto"):
+		return "Auto"
+	case strings.Contains(n, "manual"):
+		return "Manual"
+	case strings.Contains(n, "quick"):
+		return "Quick"
+	default:
+		return "Other"
+	}
+}
+
+func findScreenshot(path string) string {
+	candidates := []string{"screenshot.png", "screenshot.jpg", "screenshot.jpeg", "screenshot.bmp"}
+	for _, c := range candidates {
+		fp := filepath.Join(path, c)
+		if _, err := os.Stat(fp); err == nil {
+			return filepath.ToSlash(filepath.Join(filepath.Base(path), c))
+		}
+	}
+	return ""
+}
+
+// SaveMetadata mirrors the "metadata" block of a save's metadata.*.json. Fields
+// the game does not write for a given version are left zero; Raw keeps the block
+// verbatim so nothing is lost when the game adds keys.
+type SaveMetadata struct {
+	Name            string     `json:"name"`
+	TimestampString string     `json:"timestampString"`
+	SaveVersion     FlexString `json:"saveVersion"`
+	GameVersion     FlexString `json:"gameVersion"`
+	BuildPatch      FlexString `json:"buildPatch"`
+	BuildSKU        FlexString `json:"buildSKU"`
+	ArchiveVersion  FlexString `json:"archiveVersion"`
+	Platform        string     `json:"platform"`
+
+	LifePath    string `json:"lifePath"`
+	BodyGender  string `json:"bodyGender"`
+	BrainGender string `json:"brainGender"`
+	Difficulty  string `json:"difficulty"`
+
+	Level           float64 `json:"level"`
+	StreetCred      float64 `json:"streetCred"`
+	Money           float64 `json:"money"`
+	PlayTime        float64 `json:"playTime"`
+	PlaythroughTime float64 `json:"playthroughTime"`
+
+	Strength         float64 `json:"strength"`
+	Intelligence     float64 `json:"intelligence"`
+	Reflexes         float64 `json:"reflexes"`
+	TechnicalAbility float64 `json:"technicalAbility"`
+	Cool             float64 `json:"cool"`
+	AttributePoints  float64 `json:"attributePoints"`
+	PerkPoints       float64 `json:"perkPoints"`
+	RelicPoints      float64 `json:"relicPoints"`
+
+	Gunslinger    float64 `json:"gunslinger"`
+	Assault       float64 `json:"assault"`
+	Demolition    float64 `json:"demolition"`
+	Athletics     float64 `json:"athletics"`
+	Brawling      float64 `json:"brawling"`
+	ColdBlood     float64 `json:"coldBlood"`
+	Stealth       float64 `json:"stealth"`
+	Engineering   float64 `json:"engineering"`
+	Crafting      float64 `json:"crafting"`
+	Hacking       float64 `json:"hacking"`
+	CombatHacking float64 `json:"combatHacking"`
+
+	LocationName   string        `json:"locationName"`
+	PlayerPosition WorldPosition `json:"playerPosition"`
+
+	TrackedQuestEntry string    `json:"trackedQuestEntry"`
+	TrackedQuest      string    `json:"trackedQuest"`
+	MainQuest         string    `json:"mainQuest"`
+	ActiveQuests      QuestList `json:"activeQuests"`
+	FinishedQuests    QuestList `json:"finishedQuests"`
+
+	AdditionalContentIDs []string `json:"additionalContentIds"`
+	IsModded             bool     `json:"isModded"`
+	IsEndGameSave        bool     `json:"isEndGameSave"`
+	IsPointOfNoReturn    bool     `json:"isPointOfNoReturn"`
+	IsValid              bool     `json:"isValid"`
+
+	InitialLoadingScreenID FlexString `json:"initialLoadingScreenID"`
+
+	Raw map[string]any `json:"raw"`
+}
+
+type WorldPosition struct {
+	X float64 `json:"X"`
+	Y float64 `json:"Y"`
+	Z float64 `json:"Z"`
+	W float64 `json:"W"`
+}
+
+// FlexString accepts either a JSON string or number; version fields have been
+// written both ways across game patches.
+type FlexString string
+
+func (f *FlexString) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*f = FlexString(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*f = FlexString(n.String())
+	return nil
+}
+
+// QuestList accepts a JSON array of quest paths or a single string with the
+// paths separated by whitespace or commas.
+type QuestList []string
+
+func (q *QuestList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*q = list
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*q = strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || unicode.IsSpace(r)
+	})
+	return nil
+}
+
+func (m SaveMetadata) hasDLC(id string) bool {
+	for _, c := range m.AdditionalContentIDs {
+		if strings.EqualFold(c, id) {
+			return true
+		}
+	}
+	return false
+}
+
+func findMetadataFile(saveDir string) string {
+	files, _ := filepath.Glob(filepath.Join(saveDir, "metadata*.json"))
+	if len(files) == 0 {
+		return ""
+	}
+	return files[0]
+}
+
+func parseMetadata(saveDir string) (SaveMetadata, error) {
+	file := findMetadataFile(saveDir)
+	if file == "" {
+		return SaveMetadata{}, fmt.Errorf("no metadata file in %s", filepath.Base(saveDir))
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return SaveMetadata{}, err
+	}
+	return decodeMetadata(data, filepath.Base(file))
+}
+
+// decodeMetadata parses the contents of a metadata.*.json file named name.
+func decodeMetadata(data []byte, name string) (SaveMetadata, error) {
+	var doc struct {
+		Data struct {
+			Metadata json.RawMessage `json:"metadata"`
+		} `json:"Data"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return SaveMetadata{}, err
+	}
+	if len(doc.Data.Metadata) == 0 {
+		return SaveMetadata{}, fmt.Errorf("%s has no metadata block", name)
+	}
+	// A single field of an unexpected type should not hide the rest of the block.
+	var meta SaveMetadata
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal(doc.Data.Metadata, &meta); err != nil && !errors.As(err, &typeErr) {
+		return SaveMetadata{}, err
+	}
+	if err := json.Unmarshal(doc.Data.Metadata, &meta.Raw); err != nil {
+		return SaveMetadata{}, err
+	}
+	return meta, nil
+}
+
+func readMetadata(saveDir string) metaSummary {
+	meta, err := parseMetadata(saveDir)
+	if err != nil {
+		return metaSummary{}
+	}
+	qTitle, obj := quests.lookup(meta.TrackedQuestEntry)
+	return metaSummary{
+		Playtime:   formatPlaytime(meta.PlayTime),
+		Level:      formatLevel(meta.Level),
+		Quest:      trimQuest(meta.TrackedQuestEntry),
+		QuestTitle: qTitle,
+		Objective:  obj,
+	}
+}
+
+func formatPlaytime(seconds float64) string {
+	if seconds <= 0 {
+		return ""
+	}
+	d := time.Duration(seconds * float64(time.Second))
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	return fmt.Sprintf("%dh %dm", h, m)
+}
+
+func formatLevel(level float64) string {
+	if level <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("Lvl %d", int(level))
+}
+
+func trimQuest(q string) string {
+	if q == "" {
+		return ""
+	}
+	if idx := strings.LastIndex(q, "/"); idx >= 0 && idx < len(q)-1 {
+		return q[idx+1:]
+	}
+	return q
+}
+
+// SaveDetails returns everything known about one save, including its full
+// metadata block.
+func (m *Manager) SaveDetails(profile, name string) (SaveDetails, error) {
+	profile, name = SanitizeName(profile), SanitizeName(name)
+	if profile == "" || name == "" {
+		return SaveDetails{}, fmt.Errorf("%w: profile and name required", ErrInvalid)
+	}
+	savePath := filepath.Join(m.profilesDir, profile, name)
+	info, err := os.Stat(savePath)
+	if err != nil || !info.IsDir() {
+		return SaveDetails{}, fmt.Errorf("save %w", ErrNotFound)
+	}
+	meta, err := parseMetadata(savePath)
+	if err != nil {
+		return SaveDetails{}, fmt.Errorf("%w: %v", ErrUnreadable, err)
+	}
+	qTitle, obj := quests.lookup(meta.TrackedQuestEntry)
+	return SaveDetails{
+		Profile:        profile,
+		Name:           name,
+		Type:           classifySave(name),
+		Modified:       info.ModTime().Format("2006-01-02 15:04:05"),
+		Screenshot:     findScreenshot(savePath),
+		QuestTitle:     qTitle,
+		Objective:      obj,
+		PhantomLiberty: meta.hasDLC("EP1"),
+		Metadata:       meta,
+	}, nil
+}
+
+// InspectSave parses a save's sav.dat. A file that exists but does not parse
+// is returned with the parse error.
+func (m *Manager) InspectSave(profile, name string) (*savfile.File, error) {
+	profile, name = SanitizeName(profile), SanitizeName(name)
+	if profile == "" || name == "" {
+		return nil, fmt.Errorf("%w: profile and name required", ErrInvalid)
+	}
+	dat := filepath.Join(m.profilesDir, profile, name, "sav.dat")
+	if _, err := os.Stat(dat); err != nil {
+		return nil, fmt.Errorf("sav.dat %w", ErrNotFound)
+	}
+	return savfile.Check(dat)
+}
@@ -0,0 +1,52 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// DefaultGameSavePath is where Cyberpunk 2077 keeps saves on Windows.
+func DefaultGameSavePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, "Saved Games", "CD Projekt Red", "Cyberpunk 2077")
+}
+
+func detectGameSavePath() (string, bool) {
+	p := DefaultGameSavePath()
+	return p, dirExists(p)
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// pathKey makes a path comparable: absolute and clean, and case-folded on
+// Windows, whose file systems ignore case. Elsewhere "a" and "A" are
+// different folders.
+func pathKey(p string) string {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		abs = filepath.Clean(p)
+	}
+	if runtime.GOOS == "windows" {
+		abs = strings.ToLower(abs)
+	}
+	return abs
+}
+
+func samePath(a, b string) bool {
+	return pathKey(a) == pathKey(b)
+}
+
+// pointsIntoProfiles reports whether target lies inside profilesDir, not
+// merely beside it with a name that shares the prefix.
+func pointsIntoProfiles(target, profilesDir string) bool {
+	rel, err := filepath.Rel(pathKey(profilesDir), pathKey(target))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,58 @@
+//go:build !windows
+
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSamePathIsCaseSensitive(t *testing.T) {
+	if samePath("/x/profiles/a", "/x/profiles/A") {
+		t.Error("a and A compared equal")
+	}
+	if !samePath("/x/profiles/a/", "/x/profiles/./a") {
+		t.Error("equivalent paths compared different")
+	}
+}
+
+func TestPointsIntoProfiles(t *testing.T) {
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"/x/profiles/A", true},
+		{"/x/profiles/A/ManualSave-0", true},
+		{"/x/profiles", false},
+		{"/x/profilesOld", false},
+		{"/x/profilesOld/A", false},
+		{"/x/profiles/../other", false},
+		{"/x/Profiles/A", false},
+	}
+	for _, tt := range tests {
+		if got := pointsIntoProfiles(tt.target, "/x/profiles"); got != tt.want {
+			t.Errorf("pointsIntoProfiles(%q) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestRenameProfileCaseCollision(t *testing.T) {
+	dir := t.TempDir()
+	m, err := New(Options{ProfilesDir: filepath.Join(dir, "profiles"), GameSavePath: filepath.Join(dir, "saves"), KeysDir: filepath.Join(dir, "keys")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"a", "A"} {
+		if err := os.MkdirAll(filepath.Join(dir, "profiles", p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.RenameProfile("a", "A"); !errors.Is(err, ErrExists) {
+		t.Fatalf("rename a -> A with A present: %v", err)
+	}
+	if !dirExists(filepath.Join(dir, "profiles", "a")) || !dirExists(filepath.Join(dir, "profiles", "A")) {
+		t.Fatal("a profile went missing")
+	}
+}
@@ -0,0 +1,197 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	profileMetaFile = "profile.json"
+	// legacyNoteFile is the free-text note profile.json replaced.
+	legacyNoteFile = ".note.txt"
+)
+
+// profileMetaMu serialises read-modify-write cycles on profileMetaFile.
+var profileMetaMu sync.Mutex
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ProfileMeta is what profile.json records about a playthrough. Created,
+// LastPlayed and Prune are kept by CyberSaver; the rest is the user's.
+type ProfileMeta struct {
+	Description   string     `json:"description"`
+	CharacterName string     `json:"characterName"`
+	LifePath      string     `json:"lifePath"`
+	BuildNotes    string     `json:"buildNotes"`
+	Color         string     `json:"color"`
+	Icon          string     `json:"icon"`
+	Mods          []string   `json:"mods"`
+	Created       *time.Time `json:"created,omitempty"`
+	LastPlayed    *time.Time `json:"lastPlayed,omitempty"`
+	// Prune is set through SetPrunePolicy.
+	Prune *PrunePolicy `json:"prune,omitempty"`
+}
+
+// Profile is a profile folder with its metadata.
+type Profile struct {
+	Name string `json:"name"`
+	ProfileMeta
+}
+
+func readProfileMeta(profilesDir, profile string) ProfileMeta {
+	var meta ProfileMeta
+	if data, err := os.ReadFile(filepath.Join(profilesDir, profile, profileMetaFile)); err == nil {
+		_ = json.Unmarshal(data, &meta)
+	}
+	if meta.Mods == nil {
+		meta.Mods = []string{}
+	}
+	return meta
+}
+
+func writeProfileMeta(profilesDir, profile string, meta ProfileMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(profilesDir, profile, profileMetaFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// editProfileMeta applies fn to a profile's metadata and saves the result.
+func editProfileMeta(profilesDir, profile string, fn func(*ProfileMeta)) (ProfileMeta, error) {
+	profileMetaMu.Lock()
+	defer profileMetaMu.Unlock()
+	defer lockProfilesFile(profilesDir, metaLockFile, true)()
+	meta := readProfileMeta(profilesDir, profile)
+	fn(&meta)
+	return meta, writeProfileMeta(profilesDir, profile, meta)
+}
+
+// migrateProfileMeta gives a profile without profile.json one, taking the
+// description from its old .note.txt, which is then removed.
+func migrateProfileMeta(profilesDir, profile string) error {
+	base := filepath.Join(profilesDir, profile)
+	if _, err := os.Stat(filepath.Join(base, profileMetaFile)); !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	meta := ProfileMeta{Mods: []string{}}
+	notePath := filepath.Join(base, legacyNoteFile)
+	if data, err := os.ReadFile(notePath); err == nil {
+		meta.Description = strings.TrimSpace(string(data))
+	}
+	if info, err := os.Stat(base); err == nil {
+		created := info.ModTime()
+		meta.Created = &created
+	}
+	if err := writeProfileMeta(profilesDir, profile, meta); err != nil {
+		return err
+	}
+	if err := os.Remove(notePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// migrateProfiles converts every profile's .note.txt to profile.json.
+func migrateProfiles(profilesDir string) {
+	profileMetaMu.Lock()
+	defer profileMetaMu.Unlock()
+	defer lockProfilesFile(profilesDir, metaLockFile, true)()
+	for _, p := range profileNames(profilesDir) {
+		_ = migrateProfileMeta(profilesDir, p)
+	}
+}
+
+// isProfileFile reports whether name, relative to a profile, is one of the
+// files CyberSaver keeps about the profile rather than a game file.
+func isProfileFile(name string) bool {
+	return name == profileMetaFile || name == legacyNoteFile || name == saveMetaFile
+}
+
+func profileNames(profilesDir string) []string {
+	entries, err := os.ReadDir(profilesDir)
+	if err != nil {
+		return []string{}
+	}
+	res := []string{}
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			res = append(res, e.Name())
+		}
+	}
+	return res
+}
+
+func (m *Manager) ProfileMeta(profile string) (ProfileMeta, error) {
+	profile = SanitizeName(profile)
+	if profile == "" {
+		return ProfileMeta{}, fmt.Errorf("%w: profile required", ErrInvalid)
+	}
+	if !dirExists(filepath.Join(m.profilesDir, profile)) {
+		return ProfileMeta{}, fmt.Errorf("profile %s %w", profile, ErrNotFound)
+	}
+	return readProfileMeta(m.profilesDir, profile), nil
+}
+
+// SetProfileMeta replaces the user's fields of a profile's metadata; Created,
+// LastPlayed and Prune are kept.
+func (m *Manager) SetProfileMeta(profile string, meta ProfileMeta) (ProfileMeta, error) {
+	if _, err := m.ProfileMeta(profile); err != nil {
+		return ProfileMeta{}, err
+	}
+	profile = SanitizeName(profile)
+	meta.Color = strings.TrimSpace(meta.Color)
+	if meta.Color != "" && !colorPattern.MatchString(meta.Color) {
+		return ProfileMeta{}, fmt.Errorf("%w: color must look like #rrggbb", ErrInvalid)
+	}
+	meta.Icon = strings.TrimSpace(meta.Icon)
+	if len(meta.Icon) > 64 {
+		return ProfileMeta{}, fmt.Errorf("%w: icon too long", ErrInvalid)
+	}
+	mods := []string{}
+	for _, mod := range meta.Mods {
+		if mod = strings.TrimSpace(mod); mod != "" {
+			mods = append(mods, mod)
+		}
+	}
+	meta.Mods = mods
+	return editProfileMeta(m.profilesDir, profile, func(old *ProfileMeta) {
+		meta.Created, meta.LastPlayed, meta.Prune = old.Created, old.LastPlayed, old.Prune
+		*old = meta
+	})
+}
+
+// touchLastPlayed records that profile was just played.
+func (m *Manager) touchLastPlayed(profile string) {
+	if profile == "" {
+		return
+	}
+	now := time.Now()
+	_, _ = editProfileMeta(m.profilesDir, profile, func(meta *ProfileMeta) {
+		meta.LastPlayed = &now
+	})
+}
+
+// Profiles lists the profiles with their metadata, by name.
+func (m *Manager) Profiles() []Profile {
+	names := profileNames(m.profilesDir)
+	sort.Strings(names)
+	res := make([]Profile, 0, len(names))
+	for _, name := range names {
+		res = append(res, Profile{Name: name, ProfileMeta: readProfileMeta(m.profilesDir, name)})
+	}
+	return res
+}
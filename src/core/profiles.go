@@ -0,0 +1,336 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Errors returned by Manager operations; match them with errors.Is.
+var (
+	ErrInvalid     = errors.New("invalid request")
+	ErrNotFound    = errors.New("not found")
+	ErrExists      = errors.New("already exists")
+	ErrActive      = errors.New("profile is active, unload first")
+	ErrGameRunning = errors.New("Cyberpunk is running")
+	ErrNoGamePath  = errors.New("game save path not set")
+	ErrPinned      = errors.New("snapshot is pinned")
+	ErrUnreadable  = errors.New("unreadable")
+	ErrTampered    = errors.New("archive does not match its manifest")
+	// ErrSwitchPending means an interrupted switch could not be rolled back;
+	// RecoverSwitch retries or clears it.
+	ErrSwitchPending = errors.New("an interrupted profile switch needs recovery")
+)
+
+// DamagedError is returned when an operation would touch damaged saves and was
+// not forced.
+type DamagedError struct {
+	Msg      string
+	Problems []SaveProblem
+}
+
+func (e *DamagedError) Error() string { return e.Msg }
+
+func (m *Manager) CreateProfile(name string) (string, error) {
+	name = SanitizeName(name)
+	if name == "" {
+		return "", fmt.Errorf("%w: name required", ErrInvalid)
+	}
+	path := filepath.Join(m.profilesDir, name)
+	if _, err := os.Stat(path); err == nil {
+		return "", fmt.Errorf("profile %w", ErrExists)
+	}
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	return name, writeProfileMeta(m.profilesDir, name, ProfileMeta{Mods: []string{}, Created: &now})
+}
+
+// DeleteProfile moves a profile and its snapshots to the trash.
+func (m *Manager) DeleteProfile(name string) (TrashItem, error) {
+	name = SanitizeName(name)
+	if name == "" {
+		return TrashItem{}, fmt.Errorf("%w: invalid profile", ErrInvalid)
+	}
+	target := filepath.Join(m.profilesDir, name)
+	if !dirExists(target) {
+		return TrashItem{}, fmt.Errorf("profile %s %w", name, ErrNotFound)
+	}
+	if link, _ := m.linker.Target(m.gameSavePath); samePath(link, target) {
+		return TrashItem{}, ErrActive
+	}
+	item, err := moveToTrash(m.profilesDir, target, TrashItem{Kind: TrashProfile, Profile: name})
+	if err != nil {
+		return TrashItem{}, err
+	}
+	if err := trashSnapshots(m.profilesDir, name, item.ID); err != nil {
+		log.Printf("delete %s: snapshots not moved to the trash: %v", name, err)
+	}
+	return item, nil
+}
+
+// RenameProfile renames a profile with its snapshots. When it is the active
+// profile the game save folder is re-pointed at the new name, and the rename
+// undone if that fails.
+func (m *Manager) RenameProfile(name, newName string) (string, error) {
+	if m.running.Load() {
+		return "", fmt.Errorf("cannot rename while %w", ErrGameRunning)
+	}
+	name, newName = SanitizeName(name), SanitizeName(newName)
+	if name == "" || newName == "" {
+		return "", fmt.Errorf("%w: profile and new name required", ErrInvalid)
+	}
+	src, dest := filepath.Join(m.profilesDir, name), filepath.Join(m.profilesDir, newName)
+	if !dirExists(src) {
+		return "", fmt.Errorf("profile %s %w", name, ErrNotFound)
+	}
+	if _, err := os.Stat(dest); err == nil && !samePath(src, dest) {
+		return "", fmt.Errorf("profile %s %w", newName, ErrExists)
+	}
+	if m.ActiveProfile() == name {
+		// The rename and relink are journaled together so a crash between
+		// them cannot leave the game pointing at the old name.
+		if err := renameAndSwitch(m.linker, m.profilesDir, m.gameSavePath, src, dest); err != nil {
+			m.switchFailed(dest, err)
+			return "", err
+		}
+	} else if err := os.Rename(src, dest); err != nil {
+		return "", err
+	}
+	if err := moveSnapshots(m.profilesDir, name, newName, false); err != nil {
+		log.Printf("rename %s: snapshots not moved: %v", name, err)
+	}
+	m.backups.profileRenamed(name, newName)
+	m.watcher.rootsChanged()
+	m.events.publish("profile", map[string]string{"renamed": name, "profile": newName})
+	return newName, nil
+}
+
+// CloneProfile copies a profile, with its metadata, save annotations and
+// snapshots, under a new name.
+func (m *Manager) CloneProfile(name, newName string) (string, error) {
+	if m.running.Load() {
+		return "", fmt.Errorf("cannot clone while %w", ErrGameRunning)
+	}
+	name, newName = SanitizeName(name), SanitizeName(newName)
+	if name == "" || newName == "" {
+		return "", fmt.Errorf("%w: profile and new name required", ErrInvalid)
+	}
+	src, dest := filepath.Join(m.profilesDir, name), filepath.Join(m.profilesDir, newName)
+	if !dirExists(src) {
+		return "", fmt.Errorf("profile %s %w", name, ErrNotFound)
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return "", fmt.Errorf("profile %s %w", newName, ErrExists)
+	}
+	j := m.startJob("clone", name)
+	err := copyDir(src, dest)
+	if err == nil {
+		err = moveSnapshots(m.profilesDir, name, newName, true)
+	}
+	if err != nil {
+		j.finish(err)
+		_ = os.RemoveAll(dest)
+		_ = os.RemoveAll(snapshotRoot(m.profilesDir, newName))
+		return "", err
+	}
+	// The copy is complete without fresh dates, so only log a failure here.
+	now := time.Now()
+	if _, err := editProfileMeta(m.profilesDir, newName, func(meta *ProfileMeta) {
+		meta.Created, meta.LastPlayed = &now, nil
+	}); err != nil {
+		log.Printf("clone %s: dates not reset: %v", newName, err)
+	}
+	j.finish(nil)
+	m.events.publish("profile", map[string]string{"profile": newName})
+	return newName, nil
+}
+
+// LoadProfile points the game save folder at a profile and returns the saves
+// in it that look damaged. They do not stop the switch, since the game can
+// still load the others; only a profile whose saves are all damaged is
+// refused, unless force is set.
+func (m *Manager) LoadProfile(name string, force bool) ([]SaveProblem, error) {
+	if m.gameSavePath == "" {
+		return nil, ErrNoGamePath
+	}
+	if m.running.Load() {
+		return nil, fmt.Errorf("cannot switch while %w", ErrGameRunning)
+	}
+	name = SanitizeName(name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: invalid profile", ErrInvalid)
+	}
+	problems, saves := m.checkSaves(name)
+	if len(problems) > 0 && len(problems) == saves && !force {
+		return nil, &DamagedError{Msg: "every save in the profile looks damaged", Problems: problems}
+	}
+	target := filepath.Join(m.profilesDir, name)
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return nil, err
+	}
+	if err := switchJunction(m.linker, m.profilesDir, m.gameSavePath, target); err != nil {
+		m.switchFailed(target, err)
+		return nil, err
+	}
+	m.watcher.rootsChanged()
+	m.events.publish("profile", map[string]string{"active": name})
+	return problems, nil
+}
+
+// ImportProfile copies the current game saves into a profile as a job.
+func (m *Manager) ImportProfile(name string) error {
+	if m.gameSavePath == "" {
+		return ErrNoGamePath
+	}
+	if m.running.Load() {
+		return fmt.Errorf("cannot import while %w", ErrGameRunning)
+	}
+	name = SanitizeName(name)
+	if name == "" {
+		return fmt.Errorf("%w: invalid profile", ErrInvalid)
+	}
+	j := m.startJob("import", name)
+	err := m.importFromGamePath(name, j.progress)
+	j.finish(err)
+	if err != nil {
+		return err
+	}
+	_ = migrateProfileMeta(m.profilesDir, name)
+	m.watcher.refresh(filepath.Join(m.profilesDir, name))
+	return nil
+}
+
+// CopySave copies a save into another profile and returns where it landed;
+// an existing save of the same name is kept and the copy gets a suffix.
+func (m *Manager) CopySave(profile, name, target string, force bool) (string, error) {
+	profile, name, target = SanitizeName(profile), SanitizeName(name), SanitizeName(target)
+	if profile == "" || name == "" || target == "" {
+		return "", fmt.Errorf("%w: missing fields", ErrInvalid)
+	}
+	srcPath := filepath.Join(m.profilesDir, profile, name)
+	if _, err := os.Stat(srcPath); err != nil {
+		return "", fmt.Errorf("source save %w", ErrNotFound)
+	}
+	if c := m.integrity.check(srcPath); !c.OK && !force {
+		return "", &DamagedError{Msg: "save looks damaged", Problems: []SaveProblem{{Name: name, Reason: c.Reason}}}
+	}
+	destDir := filepath.Join(m.profilesDir, target)
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return "", err
+	}
+	destPath := filepath.Join(destDir, name)
+	if _, err := os.Stat(destPath); err == nil {
+		destPath = filepath.Join(destDir, name+"_copy_"+time.Now().Format("20060102_150405"))
+	}
+	if err := copyDir(srcPath, destPath); err != nil {
+		return "", err
+	}
+	if sm := m.SaveMeta(profile, name); !sm.empty() {
+		_ = editSaveMeta(m.profilesDir, target, func(meta map[string]SaveMeta) { meta[filepath.Base(destPath)] = sm })
+	}
+	m.watcher.refresh(destDir)
+	return destPath, nil
+}
+
+// DeleteSave moves a save, with its tags and label, to the trash.
+func (m *Manager) DeleteSave(profile, name string) (TrashItem, error) {
+	profile, name = SanitizeName(profile), SanitizeName(name)
+	if profile == "" || name == "" {
+		return TrashItem{}, ErrInvalid
+	}
+	target := filepath.Join(m.profilesDir, profile, name)
+	if !dirExists(target) {
+		return TrashItem{}, fmt.Errorf("save %s %w", name, ErrNotFound)
+	}
+	item, err := m.trashSave(profile, name)
+	if err != nil {
+		return TrashItem{}, err
+	}
+	m.watcher.refresh(filepath.Join(m.profilesDir, profile))
+	return item, nil
+}
+
+func (m *Manager) trashSave(profile, name string) (TrashItem, error) {
+	target := filepath.Join(m.profilesDir, profile, name)
+	item := TrashItem{Kind: TrashSave, Profile: profile, Name: name}
+	if sm := m.SaveMeta(profile, name); !sm.empty() {
+		item.SaveMeta = &sm
+	}
+	item, err := moveToTrash(m.profilesDir, target, item)
+	if err != nil {
+		return TrashItem{}, err
+	}
+	m.integrity.forget(target)
+	_ = editSaveMeta(m.profilesDir, profile, func(meta map[string]SaveMeta) { delete(meta, name) })
+	return item, nil
+}
+
+func (m *Manager) TakeSnapshot(profile, name string) (Snapshot, error) {
+	profile = SanitizeName(profile)
+	if profile == "" {
+		return Snapshot{}, fmt.Errorf("%w: profile required", ErrInvalid)
+	}
+	j := m.startJob("snapshot", profile)
+	snap, err := createSnapshot(m.profilesDir, profile, name, snapshotManual, j.progress)
+	j.finish(err)
+	snap.Files = nil
+	return snap, err
+}
+
+// RestoreSnapshot restores a snapshot and returns the id of the
+// snapshot taken of the state it replaced.
+func (m *Manager) RestoreSnapshot(profile, id string) (string, error) {
+	if m.running.Load() {
+		return "", fmt.Errorf("cannot restore while %w", ErrGameRunning)
+	}
+	profile = SanitizeName(profile)
+	if profile == "" || id == "" {
+		return "", fmt.Errorf("%w: profile and id required", ErrInvalid)
+	}
+	j := m.startJob("restore", profile)
+	pre, err := restoreSnapshot(m.profilesDir, profile, id, j.progress)
+	j.finish(err)
+	if err != nil {
+		return "", err
+	}
+	// Snapshots taken before profile.json existed bring back a .note.txt.
+	_ = migrateProfileMeta(m.profilesDir, profile)
+	m.watcher.refresh(filepath.Join(m.profilesDir, profile))
+	return pre.ID, nil
+}
+
+type VerifyResult struct {
+	Name string `json:"name"`
+	SaveCheck
+}
+
+// Verify checks every save of a profile; refresh discards cached
+// results first.
+func (m *Manager) Verify(profile string, refresh bool) ([]VerifyResult, error) {
+	profile = SanitizeName(profile)
+	if profile == "" {
+		return nil, fmt.Errorf("%w: profile required", ErrInvalid)
+	}
+	base := filepath.Join(m.profilesDir, profile)
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s %w", profile, ErrNotFound)
+	}
+	res := []VerifyResult{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		savePath := filepath.Join(base, e.Name())
+		if refresh {
+			m.integrity.forget(savePath)
+		}
+		res = append(res, VerifyResult{Name: e.Name(), SaveCheck: m.integrity.check(savePath)})
+	}
+	return res, nil
+}
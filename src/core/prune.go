@@ -0,0 +1,174 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+)
+
+// PrunePolicy limits how many saves of each type a profile keeps. A zero
+// limit keeps every save of that type. Favorites are never pruned.
+type PrunePolicy struct {
+	KeepAuto   int `json:"keepAuto"`
+	KeepQuick  int `json:"keepQuick"`
+	KeepManual int `json:"keepManual"`
+	// QuickPerQuest keeps only the newest quicksave of each quest. Quicksaves
+	// whose quest cannot be read are left to the KeepQuick limit.
+	QuickPerQuest bool `json:"quickPerQuest"`
+	// OnGameExit prunes the active profile when the game exits.
+	OnGameExit bool `json:"onGameExit"`
+}
+
+func (p PrunePolicy) limit(saveType string) int {
+	switch saveType {
+	case "Auto":
+		return p.KeepAuto
+	case "Quick":
+		return p.KeepQuick
+	case "Manual":
+		return p.KeepManual
+	}
+	return 0
+}
+
+type PruneCandidate struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Modified string `json:"modified"`
+	Reason   string `json:"reason"`
+}
+
+// PruneResult lists the saves a policy removes, or would remove on a dry run.
+// Removed saves go to the trash.
+type PruneResult struct {
+	Profile string           `json:"profile"`
+	Policy  PrunePolicy      `json:"policy"`
+	DryRun  bool             `json:"dryRun"`
+	Remove  []PruneCandidate `json:"remove"`
+	Kept    int              `json:"kept"`
+	Trash   []TrashItem      `json:"trash,omitempty"`
+	Errors  []string         `json:"errors,omitempty"`
+}
+
+// planPrune picks what policy removes from saves, which are newest first.
+func planPrune(saves []SaveInfo, policy PrunePolicy) (remove []PruneCandidate, kept int) {
+	remove = []PruneCandidate{}
+	seen := map[string]int{}
+	quests := map[string]bool{}
+	for _, s := range saves {
+		if s.Favorite {
+			kept++
+			continue
+		}
+		reason := ""
+		if s.Type == "Quick" && policy.QuickPerQuest {
+			quest := s.Quest
+			if quest == "" {
+				quest = s.QuestTitle
+			}
+			if quest != "" {
+				if quests[quest] {
+					reason = "older quicksave of the same quest"
+				}
+				quests[quest] = true
+			}
+		}
+		if reason == "" {
+			if n := policy.limit(s.Type); n > 0 {
+				if seen[s.Type] >= n {
+					reason = fmt.Sprintf("beyond the %d newest %s saves", n, s.Type)
+				}
+				seen[s.Type]++
+			}
+		}
+		if reason == "" {
+			kept++
+			continue
+		}
+		remove = append(remove, PruneCandidate{Name: s.Name, Type: s.Type, Modified: s.Modified, Reason: reason})
+	}
+	return remove, kept
+}
+
+func (m *Manager) PrunePolicy(profile string) (PrunePolicy, error) {
+	meta, err := m.ProfileMeta(profile)
+	if err != nil {
+		return PrunePolicy{}, err
+	}
+	if meta.Prune == nil {
+		return PrunePolicy{}, nil
+	}
+	return *meta.Prune, nil
+}
+
+// SetPrunePolicy stores a profile's policy in its profile.json. It does not
+// prune; call Prune for that.
+func (m *Manager) SetPrunePolicy(profile string, policy PrunePolicy) (PrunePolicy, error) {
+	if policy.KeepAuto < 0 || policy.KeepQuick < 0 || policy.KeepManual < 0 {
+		return PrunePolicy{}, fmt.Errorf("%w: limits must not be negative", ErrInvalid)
+	}
+	if _, err := m.ProfileMeta(profile); err != nil {
+		return PrunePolicy{}, err
+	}
+	_, err := editProfileMeta(m.profilesDir, SanitizeName(profile), func(meta *ProfileMeta) {
+		meta.Prune = &policy
+		if policy == (PrunePolicy{}) {
+			meta.Prune = nil
+		}
+	})
+	if err != nil {
+		return PrunePolicy{}, err
+	}
+	return policy, nil
+}
+
+// Prune applies a profile's policy, moving the saves it drops to the trash.
+// With dryRun it only reports what would go.
+func (m *Manager) Prune(profile string, dryRun bool) (PruneResult, error) {
+	policy, err := m.PrunePolicy(profile)
+	if err != nil {
+		return PruneResult{}, err
+	}
+	profile = SanitizeName(profile)
+	remove, kept := planPrune(m.Saves(profile), policy)
+	res := PruneResult{Profile: profile, Policy: policy, DryRun: dryRun, Remove: remove, Kept: kept}
+	if dryRun || len(remove) == 0 {
+		return res, nil
+	}
+	if m.running.Load() && m.ActiveProfile() == profile {
+		return PruneResult{}, fmt.Errorf("cannot prune the active profile while %w", ErrGameRunning)
+	}
+	j := m.startJob("prune", profile)
+	var errs []error
+	for i, c := range remove {
+		item, err := m.trashSave(profile, c.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.Name, err))
+			res.Errors = append(res.Errors, c.Name+": "+err.Error())
+		} else {
+			res.Trash = append(res.Trash, item)
+		}
+		j.progress(i+1, len(remove))
+	}
+	j.finish(errors.Join(errs...))
+	m.watcher.refresh(filepath.Join(m.profilesDir, profile))
+	return res, nil
+}
+
+// pruneAfterExit prunes the profile that was just played when its policy
+// asks for it.
+func (m *Manager) pruneAfterExit(profile string) {
+	if profile == "" {
+		return
+	}
+	if policy, err := m.PrunePolicy(profile); err != nil || !policy.OnGameExit {
+		return
+	}
+	res, err := m.Prune(profile, false)
+	if err != nil {
+		log.Printf("prune %s after game exit: %v", profile, err)
+	} else if len(res.Trash) > 0 {
+		log.Printf("pruned %d saves from %s after game exit", len(res.Trash), profile)
+	}
+}
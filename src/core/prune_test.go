@@ -0,0 +1,25 @@
+package core
+
+import "testing"
+
+func TestPlanPruneQuickPerQuest(t *testing.T) {
+	// Newest first, as Saves returns them.
+	saves := []SaveInfo{
+		{Name: "QuickSave-5", Type: "Quick", Quest: "q/main"},
+		{Name: "QuickSave-4", Type: "Quick"},
+		{Name: "QuickSave-3", Type: "Quick", Quest: "q/main"},
+		{Name: "QuickSave-2", Type: "Quick"},
+		{Name: "QuickSave-1", Type: "Quick", Quest: "q/side", Favorite: true},
+		{Name: "QuickSave-0", Type: "Quick", Quest: "q/side"},
+	}
+	remove, kept := planPrune(saves, PrunePolicy{QuickPerQuest: true})
+	var names []string
+	for _, c := range remove {
+		names = append(names, c.Name)
+	}
+	// Unreadable quicksaves have no quest and must not be grouped together;
+	// a favorite is kept without counting as its quest's newest quicksave.
+	if len(names) != 1 || names[0] != "QuickSave-3" || kept != 5 {
+		t.Fatalf("removed %v, kept %d", names, kept)
+	}
+}
@@ -0,0 +1,147 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Quest statuses in a QuestProgress, from most to least progress.
+const (
+	QuestFinished  = "finished"
+	QuestActive    = "active"
+	QuestTracked   = "tracked"
+	QuestUntouched = "untouched"
+)
+
+var questRank = map[string]int{QuestFinished: 3, QuestActive: 2, QuestTracked: 1}
+
+type QuestStatus struct {
+	Path   string `json:"path"`
+	Title  string `json:"title"`
+	Status string `json:"status"`
+	// Objectives lists the objectives some save was tracking; TotalObjectives
+	// is how many the quest has.
+	Objectives      []string `json:"objectives"`
+	TotalObjectives int      `json:"totalObjectives"`
+}
+
+// QuestCategory is one quest type, such as MainQuest or Contract. Coverage is
+// the percentage of its quests that any save has touched.
+type QuestCategory struct {
+	Type     string        `json:"type"`
+	Total    int           `json:"total"`
+	Touched  int           `json:"touched"`
+	Finished int           `json:"finished"`
+	Coverage float64       `json:"coverage"`
+	Quests   []QuestStatus `json:"quests"`
+}
+
+type QuestProgress struct {
+	Profile    string          `json:"profile"`
+	Saves      int             `json:"saves"`
+	Categories []QuestCategory `json:"categories"`
+	// Unknown lists quest paths from the saves that the quest database does
+	// not have.
+	Unknown []string `json:"unknown"`
+}
+
+// QuestProgress gathers the quests every save of a profile tracks, has
+// active or has finished, and sets them against the full quest database by
+// quest type.
+func (m *Manager) QuestProgress(profile string) (QuestProgress, error) {
+	profile = SanitizeName(profile)
+	if profile == "" {
+		return QuestProgress{}, fmt.Errorf("%w: profile required", ErrInvalid)
+	}
+	base := filepath.Join(m.profilesDir, profile)
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return QuestProgress{}, fmt.Errorf("profile %s %w", profile, ErrNotFound)
+	}
+	res := QuestProgress{Profile: profile, Categories: []QuestCategory{}, Unknown: []string{}}
+	status := map[int]string{}
+	objectives := map[int]map[string]bool{}
+	unknown := map[string]bool{}
+	mark := func(path, st string) {
+		if path == "" {
+			return
+		}
+		i, ok := quests.quest(path)
+		if !ok {
+			unknown[normalizePath(path)] = true
+			return
+		}
+		if questRank[st] > questRank[status[i]] {
+			status[i] = st
+		}
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		meta, err := parseMetadata(filepath.Join(base, e.Name()))
+		if err != nil {
+			continue
+		}
+		res.Saves++
+		for _, q := range meta.FinishedQuests {
+			mark(q, QuestFinished)
+		}
+		for _, q := range meta.ActiveQuests {
+			mark(q, QuestActive)
+		}
+		mark(meta.TrackedQuest, QuestTracked)
+		mark(meta.TrackedQuestEntry, QuestTracked)
+		if i, ok := quests.quest(meta.TrackedQuestEntry); ok {
+			if _, doc := quests.lookup(meta.TrackedQuestEntry); doc != "" {
+				if objectives[i] == nil {
+					objectives[i] = map[string]bool{}
+				}
+				objectives[i][doc] = true
+			}
+		}
+	}
+
+	byType := map[string]*QuestCategory{}
+	for i, q := range quests.list {
+		c := byType[q.Type]
+		if c == nil {
+			c = &QuestCategory{Type: q.Type, Quests: []QuestStatus{}}
+			byType[q.Type] = c
+		}
+		st := status[i]
+		if st == "" {
+			st = QuestUntouched
+		} else {
+			c.Touched++
+		}
+		if st == QuestFinished {
+			c.Finished++
+		}
+		c.Total++
+		objs := []string{}
+		for doc := range objectives[i] {
+			objs = append(objs, doc)
+		}
+		sort.Strings(objs)
+		c.Quests = append(c.Quests, QuestStatus{Path: q.Path, Title: q.Title, Status: st, Objectives: objs, TotalObjectives: q.Objectives})
+	}
+	for _, c := range byType {
+		if c.Total > 0 {
+			c.Coverage = math.Round(float64(c.Touched)*1000/float64(c.Total)) / 10
+		}
+		sort.SliceStable(c.Quests, func(i, j int) bool {
+			return questRank[c.Quests[i].Status] > questRank[c.Quests[j].Status]
+		})
+		res.Categories = append(res.Categories, *c)
+	}
+	sort.Slice(res.Categories, func(i, j int) bool { return res.Categories[i].Type < res.Categories[j].Type })
+	for p := range unknown {
+		res.Unknown = append(res.Unknown, p)
+	}
+	sort.Strings(res.Unknown)
+	return res, nil
+}
@@ -1,4 +1,4 @@
-package main
+package core
 
 import (
 	_ "embed"
@@ -13,10 +13,23 @@ var questData []byte
 type questIndex struct {
 	pathToTitle    map[string]string
 	objectiveToDoc map[string]string
+	// list holds every quest in file order; questOf maps a quest, phase or
+	// objective path to its quest's position in list.
+	list    []questInfo
+	questOf map[string]int
+}
+
+type questInfo struct {
+	Path        string
+	Title       string
+	Type        string
+	Description string
+	Objectives  int
 }
 
 type questEntry struct {
 	Path        string       `json:"path"`
+	Type        string       `json:"type"`
 	Title       string       `json:"title"`
 	Phases      []questPhase `json:"phases"`
 	Description string       `json:"description"`
@@ -38,6 +51,7 @@ func loadQuestIndex() *questIndex {
 	idx := &questIndex{
 		pathToTitle:    map[string]string{},
 		objectiveToDoc: map[string]string{},
+		questOf:        map[string]int{},
 	}
 	if len(questData) == 0 {
 		log.Printf("quest data not embedded; quest titles will be unavailable")
@@ -49,14 +63,21 @@ func loadQuestIndex() *questIndex {
 		return idx
 	}
 	for _, q := range entries {
+		info := questInfo{Path: q.Path, Title: q.Title, Type: q.Type, Description: q.Description}
+		n := len(idx.list)
 		addPath(idx.pathToTitle, q.Path, q.Title)
+		addQuestPath(idx.questOf, q.Path, n)
 		for _, ph := range q.Phases {
 			addPath(idx.pathToTitle, ph.Path, q.Title)
+			addQuestPath(idx.questOf, ph.Path, n)
 			for _, obj := range ph.Objectives {
 				addPath(idx.pathToTitle, obj.Path, q.Title)
 				addPath(idx.objectiveToDoc, obj.Path, obj.Description)
+				addQuestPath(idx.questOf, obj.Path, n)
+				info.Objectives++
 			}
 		}
+		idx.list = append(idx.list, info)
 	}
 	return idx
 }
@@ -71,6 +92,16 @@ func addPath(m map[string]string, path, value string) {
 	}
 }
 
+func addQuestPath(m map[string]int, path string, quest int) {
+	if path == "" {
+		return
+	}
+	n := normalizePath(path)
+	if _, ok := m[n]; !ok {
+		m[n] = quest
+	}
+}
+
 func normalizePath(p string) string {
 	return strings.Trim(strings.ToLower(p), "/")
 }
@@ -104,3 +135,16 @@ func (q *questIndex) lookup(path string) (title string, objective string) {
 	}
 	return title, objective
 }
+
+// quest finds the quest a quest, phase or objective path belongs to.
+func (q *questIndex) quest(path string) (int, bool) {
+	if q == nil {
+		return 0, false
+	}
+	for cursor := normalizePath(path); cursor != ""; cursor = parentPath(cursor) {
+		if i, ok := q.questOf[cursor]; ok {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SaveRef names a save in a profile.
+type SaveRef struct {
+	Profile string `json:"profile"`
+	Name    string `json:"name"`
+}
+
+func (r SaveRef) String() string { return r.Profile + "/" + r.Name }
+
+// ParseSaveRef reads "profile/save", or a bare save name in defaultProfile.
+func ParseSaveRef(s, defaultProfile string) SaveRef {
+	if p, name, ok := strings.Cut(s, "/"); ok {
+		return SaveRef{Profile: SanitizeName(p), Name: SanitizeName(name)}
+	}
+	return SaveRef{Profile: SanitizeName(defaultProfile), Name: SanitizeName(s)}
+}
+
+// FieldChange is one metadata field that differs between two saves. Delta is
+// set for numbers.
+type FieldChange struct {
+	Field string   `json:"field"`
+	From  any      `json:"from"`
+	To    any      `json:"to"`
+	Delta *float64 `json:"delta,omitempty"`
+}
+
+type QuestRef struct {
+	Path      string `json:"path"`
+	Title     string `json:"title"`
+	Objective string `json:"objective,omitempty"`
+}
+
+type SaveDiffSide struct {
+	SaveRef
+	Modified     string   `json:"modified"`
+	TrackedQuest QuestRef `json:"trackedQuest"`
+}
+
+// SaveDiff compares the metadata of two saves. Fields lists only what
+// changed; the quest lists say how quest progress moved from From to To.
+type SaveDiff struct {
+	From   SaveDiffSide  `json:"from"`
+	To     SaveDiffSide  `json:"to"`
+	Fields []FieldChange `json:"fields"`
+	// QuestsStarted are active in To but neither active nor finished in From.
+	QuestsStarted []QuestRef `json:"questsStarted"`
+	// QuestsFinished are finished in To but not in From.
+	QuestsFinished []QuestRef `json:"questsFinished"`
+	// QuestsDropped are active in From but gone from To; going from a later
+	// save back to an earlier one lists them here.
+	QuestsDropped []QuestRef `json:"questsDropped"`
+}
+
+// DiffSaves compares two saves, which may be in different profiles.
+func (m *Manager) DiffSaves(from, to SaveRef) (SaveDiff, error) {
+	a, err := m.SaveDetails(from.Profile, from.Name)
+	if err != nil {
+		return SaveDiff{}, fmt.Errorf("%s: %w", from, err)
+	}
+	b, err := m.SaveDetails(to.Profile, to.Name)
+	if err != nil {
+		return SaveDiff{}, fmt.Errorf("%s: %w", to, err)
+	}
+	return SaveDiff{
+		From:           diffSide(a),
+		To:             diffSide(b),
+		Fields:         diffMetadata(a.Metadata, b.Metadata),
+		QuestsStarted:  questsMissing(b.Metadata.ActiveQuests, a.Metadata.ActiveQuests, a.Metadata.FinishedQuests),
+		QuestsFinished: questsMissing(b.Metadata.FinishedQuests, a.Metadata.FinishedQuests),
+		QuestsDropped:  questsMissing(a.Metadata.ActiveQuests, b.Metadata.ActiveQuests, b.Metadata.FinishedQuests),
+	}, nil
+}
+
+func diffSide(d SaveDetails) SaveDiffSide {
+	return SaveDiffSide{
+		SaveRef:      SaveRef{Profile: d.Profile, Name: d.Name},
+		Modified:     d.Modified,
+		TrackedQuest: questRef(d.Metadata.TrackedQuestEntry),
+	}
+}
+
+func questRef(path string) QuestRef {
+	title, objective := quests.lookup(path)
+	return QuestRef{Path: path, Title: title, Objective: objective}
+}
+
+// questsMissing returns the quests in list that none of the others hold.
+func questsMissing(list QuestList, others ...QuestList) []QuestRef {
+	have := map[string]bool{}
+	for _, o := range others {
+		for _, q := range o {
+			have[normalizePath(q)] = true
+		}
+	}
+	res := []QuestRef{}
+	for _, q := range list {
+		if n := normalizePath(q); n != "" && !have[n] {
+			have[n] = true
+			res = append(res, questRef(q))
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+	return res
+}
+
+// diffMetadata compares the scalar fields of two metadata blocks by their JSON
+// names. Quest lists and Raw are left to the caller.
+func diffMetadata(a, b SaveMetadata) []FieldChange {
+	res := []FieldChange{}
+	av, bv := reflect.ValueOf(a), reflect.ValueOf(b)
+	t := av.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		x, y := av.Field(i), bv.Field(i)
+		switch x.Kind() {
+		case reflect.Float64:
+			if x.Float() != y.Float() {
+				delta := y.Float() - x.Float()
+				res = append(res, FieldChange{Field: name, From: x.Float(), To: y.Float(), Delta: &delta})
+			}
+		case reflect.String, reflect.Bool, reflect.Struct:
+			if x.Interface() != y.Interface() {
+				res = append(res, FieldChange{Field: name, From: x.Interface(), To: y.Interface()})
+			}
+		case reflect.Slice:
+			if x.Type() == reflect.TypeOf([]string(nil)) {
+				xs, ys := x.Interface().([]string), y.Interface().([]string)
+				if strings.Join(xs, ",") != strings.Join(ys, ",") {
+					res = append(res, FieldChange{Field: name, From: xs, To: ys})
+				}
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// saveMetaFile holds a profile's per-save annotations. It lives in the
+// profile folder so snapshots and exports carry it along.
+const saveMetaFile = ".saves.json"
+
+// saveMetaMu serialises read-modify-write cycles on saveMetaFile.
+var saveMetaMu sync.Mutex
+
+// SaveMeta is what the user has attached to a save: tags, a favorite flag
+// and a display label used instead of the folder name.
+type SaveMeta struct {
+	Tags     []string `json:"tags"`
+	Favorite bool     `json:"favorite"`
+	Label    string   `json:"label,omitempty"`
+}
+
+func (sm SaveMeta) empty() bool {
+	return len(sm.Tags) == 0 && !sm.Favorite && sm.Label == ""
+}
+
+// SaveMetaUpdate changes some of a save's annotations; nil fields are kept.
+// Tags replaces the whole set, AddTags and RemoveTags edit it.
+type SaveMetaUpdate struct {
+	Tags       *[]string `json:"tags,omitempty"`
+	AddTags    []string  `json:"addTags,omitempty"`
+	RemoveTags []string  `json:"removeTags,omitempty"`
+	Favorite   *bool     `json:"favorite,omitempty"`
+	Label      *string   `json:"label,omitempty"`
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// normalizeTag trims a tag and folds its case so "PONR" and "ponr" match.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+func normalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	res := []string{}
+	for _, t := range tags {
+		if t = normalizeTag(t); t != "" && !seen[t] {
+			seen[t] = true
+			res = append(res, t)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func readSaveMeta(profilesDir, profile string) map[string]SaveMeta {
+	data, err := os.ReadFile(filepath.Join(profilesDir, profile, saveMetaFile))
+	if err != nil {
+		return map[string]SaveMeta{}
+	}
+	meta := map[string]SaveMeta{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return map[string]SaveMeta{}
+	}
+	return meta
+}
+
+func writeSaveMeta(profilesDir, profile string, meta map[string]SaveMeta) error {
+	path := filepath.Join(profilesDir, profile, saveMetaFile)
+	if len(meta) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// editSaveMeta applies fn to a profile's annotations and saves the result.
+func editSaveMeta(profilesDir, profile string, fn func(map[string]SaveMeta)) error {
+	saveMetaMu.Lock()
+	defer saveMetaMu.Unlock()
+	defer lockProfilesFile(profilesDir, metaLockFile, true)()
+	meta := readSaveMeta(profilesDir, profile)
+	fn(meta)
+	for name, sm := range meta {
+		if sm.empty() {
+			delete(meta, name)
+		}
+	}
+	return writeSaveMeta(profilesDir, profile, meta)
+}
+
+// annotateSaves fills in the tags, favorite flag and label of each save.
+func annotateSaves(profilesDir, profile string, saves []SaveInfo) {
+	meta := readSaveMeta(profilesDir, profile)
+	for i := range saves {
+		sm := meta[saves[i].Name]
+		saves[i].Tags = sm.Tags
+		if saves[i].Tags == nil {
+			saves[i].Tags = []string{}
+		}
+		saves[i].Favorite = sm.Favorite
+		saves[i].Label = sm.Label
+	}
+}
+
+// HasTag reports whether the save carries tag, ignoring case.
+func (s SaveInfo) HasTag(tag string) bool {
+	tag = normalizeTag(tag)
+	for _, t := range s.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) SaveMeta(profile, name string) SaveMeta {
+	sm := readSaveMeta(m.profilesDir, SanitizeName(profile))[SanitizeName(name)]
+	if sm.Tags == nil {
+		sm.Tags = []string{}
+	}
+	return sm
+}
+
+// UpdateSaveMeta changes a save's annotations and returns the result. A "meta"
+// event tells listeners to refresh.
+func (m *Manager) UpdateSaveMeta(profile, name string, u SaveMetaUpdate) (SaveMeta, error) {
+	profile, name = SanitizeName(profile), SanitizeName(name)
+	if profile == "" || name == "" {
+		return SaveMeta{}, fmt.Errorf("%w: profile and save required", ErrInvalid)
+	}
+	if !dirExists(filepath.Join(m.profilesDir, profile, name)) {
+		return SaveMeta{}, fmt.Errorf("save %s %w", name, ErrNotFound)
+	}
+	var res SaveMeta
+	err := editSaveMeta(m.profilesDir, profile, func(meta map[string]SaveMeta) {
+		sm := meta[name]
+		if u.Tags != nil {
+			sm.Tags = *u.Tags
+		}
+		sm.Tags = append(append([]string{}, sm.Tags...), u.AddTags...)
+		if len(u.RemoveTags) > 0 {
+			drop := map[string]bool{}
+			for _, t := range u.RemoveTags {
+				drop[normalizeTag(t)] = true
+			}
+			kept := sm.Tags[:0]
+			for _, t := range sm.Tags {
+				if !drop[normalizeTag(t)] {
+					kept = append(kept, t)
+				}
+			}
+			sm.Tags = kept
+		}
+		sm.Tags = normalizeTags(sm.Tags)
+		if u.Favorite != nil {
+			sm.Favorite = *u.Favorite
+		}
+		if u.Label != nil {
+			sm.Label = strings.TrimSpace(*u.Label)
+		}
+		meta[name] = sm
+		res = sm
+	})
+	if err != nil {
+		return SaveMeta{}, err
+	}
+	m.events.publish("meta", map[string]string{"profile": profile, "name": name})
+	return res, nil
+}
+
+// Tags lists the tags used in a profile with how many saves carry each.
+func (m *Manager) Tags(profile string) []TagCount {
+	counts := map[string]int{}
+	for _, sm := range readSaveMeta(m.profilesDir, SanitizeName(profile)) {
+		for _, t := range sm.Tags {
+			counts[t]++
+		}
+	}
+	res := make([]TagCount, 0, len(counts))
+	for t, n := range counts {
+		res = append(res, TagCount{Tag: t, Count: n})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Tag < res[j].Tag })
+	return res
+}
@@ -0,0 +1,355 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DefaultSearchLimit caps the results of a search that does not set a limit.
+const DefaultSearchLimit = 50
+
+// searchDoc is what the index keeps about one save between searches. The
+// user's annotations are not cached; they are read fresh for every query.
+type searchDoc struct {
+	profile, name    string
+	saveType         string
+	modified         time.Time
+	level, playTime  float64
+	quest, objective string
+	questDesc        string
+	location         string
+	lifePath         string
+
+	fingerprint string
+}
+
+// searchIndex holds a searchDoc per save directory and re-reads a save's
+// metadata only when the files in it change, like integrityCache.
+type searchIndex struct {
+	mu   sync.Mutex
+	docs map[string]searchDoc
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{docs: map[string]searchDoc{}}
+}
+
+func (x *searchIndex) doc(profile, name, saveDir string) searchDoc {
+	fp := saveFingerprint(saveDir)
+	x.mu.Lock()
+	d, ok := x.docs[saveDir]
+	x.mu.Unlock()
+	if ok && d.fingerprint == fp {
+		return d
+	}
+	d = searchDoc{profile: profile, name: name, saveType: classifySave(name), fingerprint: fp}
+	if info, err := os.Stat(saveDir); err == nil {
+		d.modified = info.ModTime()
+	}
+	if meta, err := parseMetadata(saveDir); err == nil {
+		d.level, d.playTime = meta.Level, meta.PlayTime
+		d.quest, d.objective = quests.lookup(meta.TrackedQuestEntry)
+		if i, ok := quests.quest(meta.TrackedQuestEntry); ok {
+			d.questDesc = quests.list[i].Description
+		}
+		d.location, d.lifePath = meta.LocationName, meta.LifePath
+	}
+	x.mu.Lock()
+	x.docs[saveDir] = d
+	x.mu.Unlock()
+	return d
+}
+
+// keep drops cached documents for save directories no longer in dirs.
+func (x *searchIndex) keep(dirs map[string]bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for dir := range x.docs {
+		if !dirs[dir] {
+			delete(x.docs, dir)
+		}
+	}
+}
+
+type SearchResult struct {
+	Profile    string   `json:"profile"`
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Modified   string   `json:"modified"`
+	Level      float64  `json:"level"`
+	PlayTime   float64  `json:"playTime"`
+	QuestTitle string   `json:"questTitle"`
+	Objective  string   `json:"objective"`
+	Location   string   `json:"location,omitempty"`
+	Label      string   `json:"label,omitempty"`
+	Tags       []string `json:"tags"`
+	Favorite   bool     `json:"favorite"`
+	Score      float64  `json:"score"`
+	// Matched names the fields the free-text terms were found in.
+	Matched []string `json:"matched"`
+}
+
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Total   int            `json:"total"`
+	Results []SearchResult `json:"results"`
+}
+
+// searchField is one searchable text of a save with the weight a free-text
+// hit in it adds to the score.
+type searchField struct {
+	name   string
+	text   string
+	weight float64
+}
+
+// searchTerm is one piece of a query: a free-text word or phrase when field
+// is empty, otherwise a field filter such as quest:"..." or level>40.
+type searchTerm struct {
+	field string
+	op    string
+	value string
+	num   float64
+}
+
+// queryFields are the fields a query can filter on, with whether they take a
+// number.
+var queryFields = map[string]bool{
+	"level": true, "playtime": true,
+	"quest": false, "objective": false, "desc": false, "tag": false, "type": false, "profile": false,
+	"label": false, "note": false, "location": false, "lifepath": false, "name": false, "is": false,
+}
+
+// parseQuery splits a query into terms. Values may be double-quoted to keep
+// spaces; numeric fields take :, =, <, >, <= or >=. A token is a filter only
+// when it starts with a known field name, so 12:30 or v2.0=final are free text.
+func parseQuery(q string) ([]searchTerm, error) {
+	var terms []searchTerm
+	for _, tok := range splitQuery(q) {
+		i := strings.IndexAny(tok, ":<>=")
+		numeric, known := false, false
+		if i > 0 && !strings.HasPrefix(tok, `"`) {
+			numeric, known = queryFields[strings.ToLower(tok[:i])]
+		}
+		if !known {
+			if v := strings.ToLower(unquote(tok)); v != "" {
+				terms = append(terms, searchTerm{value: v})
+			}
+			continue
+		}
+		field := strings.ToLower(tok[:i])
+		op := tok[i : i+1]
+		rest := tok[i+1:]
+		if (op == "<" || op == ">") && strings.HasPrefix(rest, "=") {
+			op, rest = op+"=", rest[1:]
+		}
+		t := searchTerm{field: field, op: op, value: strings.ToLower(unquote(rest))}
+		if numeric {
+			if op == ":" {
+				t.op = "="
+			}
+			n, err := strconv.ParseFloat(strings.TrimSuffix(t.value, "h"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s needs a number", ErrInvalid, field)
+			}
+			t.num = n
+		} else if op != ":" {
+			return nil, fmt.Errorf("%w: use %s:value", ErrInvalid, field)
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+// splitQuery splits on spaces outside double quotes.
+func splitQuery(q string) []string {
+	var res []string
+	var b strings.Builder
+	quoted := false
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			b.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if b.Len() > 0 {
+				res = append(res, b.String())
+				b.Reset()
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() > 0 {
+		res = append(res, b.String())
+	}
+	return res
+}
+
+func unquote(s string) string {
+	return strings.TrimSpace(strings.Trim(s, `"`))
+}
+
+func compareNum(v float64, op string, n float64) bool {
+	switch op {
+	case "<":
+		return v < n
+	case "<=":
+		return v <= n
+	case ">":
+		return v > n
+	case ">=":
+		return v >= n
+	}
+	return v == n
+}
+
+func containsFold(text, sub string) bool {
+	return strings.Contains(strings.ToLower(text), sub)
+}
+
+// Search looks through every save of every profile. Field filters must all
+// match; free-text terms must each be found somewhere and rank the results,
+// with hits in the quest title, label and tags counting most. limit <= 0
+// means DefaultSearchLimit.
+func (m *Manager) Search(query string, limit int) (SearchResponse, error) {
+	terms, err := parseQuery(query)
+	if err != nil {
+		return SearchResponse{}, err
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	res := SearchResponse{Query: query, Results: []SearchResult{}}
+	seen := map[string]bool{}
+	for _, profile := range profileNames(m.profilesDir) {
+		base := filepath.Join(m.profilesDir, profile)
+		entries, err := os.ReadDir(base)
+		if err != nil {
+			continue
+		}
+		pm := readProfileMeta(m.profilesDir, profile)
+		notes := strings.Join([]string{pm.Description, pm.BuildNotes, pm.CharacterName}, "\n")
+		annotations := readSaveMeta(m.profilesDir, profile)
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			dir := filepath.Join(base, e.Name())
+			seen[dir] = true
+			d := m.search.doc(profile, e.Name(), dir)
+			sm := annotations[e.Name()]
+			if r, ok := matchSave(d, sm, notes, terms); ok {
+				res.Results = append(res.Results, r)
+			}
+		}
+	}
+	m.search.keep(seen)
+	sort.SliceStable(res.Results, func(i, j int) bool {
+		a, b := res.Results[i], res.Results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Modified > b.Modified
+	})
+	res.Total = len(res.Results)
+	if len(res.Results) > limit {
+		res.Results = res.Results[:limit]
+	}
+	return res, nil
+}
+
+func matchSave(d searchDoc, sm SaveMeta, notes string, terms []searchTerm) (SearchResult, bool) {
+	tags := strings.Join(sm.Tags, " ")
+	fields := []searchField{
+		{"quest", d.quest, 5},
+		{"label", sm.Label, 4},
+		{"tags", tags, 4},
+		{"objective", d.objective, 3},
+		{"name", d.name, 2},
+		{"location", d.location, 2},
+		{"notes", notes, 1},
+		{"questDescription", d.questDesc, 1},
+		{"profile", d.profile, 1},
+	}
+	r := SearchResult{
+		Profile:    d.profile,
+		Name:       d.name,
+		Type:       d.saveType,
+		Modified:   d.modified.Format("2006-01-02 15:04:05"),
+		Level:      d.level,
+		PlayTime:   d.playTime,
+		QuestTitle: d.quest,
+		Objective:  d.objective,
+		Location:   d.location,
+		Label:      sm.Label,
+		Tags:       sm.Tags,
+		Favorite:   sm.Favorite,
+		Matched:    []string{},
+	}
+	if r.Tags == nil {
+		r.Tags = []string{}
+	}
+	matched := map[string]bool{}
+	for _, t := range terms {
+		ok := true
+		switch t.field {
+		case "":
+			ok = false
+			for _, f := range fields {
+				if f.text != "" && containsFold(f.text, t.value) {
+					ok = true
+					r.Score += f.weight
+					matched[f.name] = true
+				}
+			}
+		case "level":
+			ok = compareNum(d.level, t.op, t.num)
+		case "playtime":
+			ok = compareNum(d.playTime/3600, t.op, t.num)
+		case "quest":
+			ok = containsFold(d.quest, t.value)
+		case "objective":
+			ok = containsFold(d.objective, t.value)
+		case "desc":
+			ok = containsFold(d.questDesc, t.value)
+		case "tag":
+			ok = SaveInfo{Tags: sm.Tags}.HasTag(t.value)
+		case "type":
+			ok = strings.EqualFold(d.saveType, t.value)
+		case "profile":
+			ok = strings.EqualFold(d.profile, t.value)
+		case "label":
+			ok = containsFold(sm.Label, t.value)
+		case "note":
+			ok = containsFold(notes, t.value)
+		case "location":
+			ok = containsFold(d.location, t.value)
+		case "lifepath":
+			ok = containsFold(d.lifePath, t.value)
+		case "name":
+			ok = containsFold(d.name, t.value)
+		case "is":
+			ok = t.value == "favorite" && sm.Favorite
+		}
+		if !ok {
+			return SearchResult{}, false
+		}
+	}
+	if sm.Favorite && r.Score > 0 {
+		r.Score += 0.5
+	}
+	for _, f := range fields {
+		if matched[f.name] {
+			r.Matched = append(r.Matched, f.name)
+		}
+	}
+	return r, true
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []searchTerm
+	}{
+		{`phantom`, []searchTerm{{value: "phantom"}}},
+		{`quest:"Phantom Liberty" level>40`, []searchTerm{
+			{field: "quest", op: ":", value: "phantom liberty"},
+			{field: "level", op: ">", value: "40", num: 40},
+		}},
+		{`playtime<=12h`, []searchTerm{{field: "playtime", op: "<=", value: "12h", num: 12}}},
+		{`Level:50`, []searchTerm{{field: "level", op: "=", value: "50", num: 50}}},
+		// Unknown prefixes are free text, not filters.
+		{`12:30 v2.0=final`, []searchTerm{{value: "12:30"}, {value: "v2.0=final"}}},
+		{`"quest:x"`, []searchTerm{{value: "quest:x"}}},
+		{`:odd`, []searchTerm{{value: ":odd"}}},
+	}
+	for _, tt := range tests {
+		got, err := parseQuery(tt.query)
+		if err != nil {
+			t.Errorf("parseQuery(%q): %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseQuery(%q) = %+v, want %+v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, q := range []string{`level:high`, `quest>3`, `tag=boss`} {
+		if _, err := parseQuery(q); !errors.Is(err, ErrInvalid) {
+			t.Errorf("parseQuery(%q) = %v, want ErrInvalid", q, err)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	signatureName   = "manifest.sig"
+	signingKeyFile  = "export_ed25519.pem"
+	trustedKeysFile = "trusted_keys.json"
+	// legacyKeysDir is where older versions kept the key, inside the
+	// profiles folder.
+	legacyKeysDir = ".keys"
+)
+
+// signingKeyMu keeps two first exports from generating different keys and
+// guards trusted_keys.json.
+var signingKeyMu sync.Mutex
+
+// Signature is stored as manifest.sig next to manifest.json and signs the
+// manifest's exact bytes.
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+// SigningKey identifies the local export signing key. KeyID is what to
+// compare with a teammate before trusting their archives.
+type SigningKey struct {
+	KeyID     string `json:"keyId"`
+	PublicKey string `json:"publicKey"`
+}
+
+// ArchiveVerification describes what an archive's manifest vouches for.
+// Archives without a manifest, such as ones zipped by hand, still import but
+// have Manifest false.
+type ArchiveVerification struct {
+	Manifest bool      `json:"manifest"`
+	Profile  string    `json:"profile,omitempty"`
+	Created  time.Time `json:"created,omitempty"`
+	Files    int       `json:"files"`
+	Signed   bool      `json:"signed"`
+	// Signer is the name the manifest gives, which anyone can write; only
+	// Trusted says the key is known.
+	Signer string `json:"signer,omitempty"`
+	KeyID  string `json:"keyId,omitempty"`
+	// OwnKey is set when the archive was signed with this machine's key.
+	OwnKey bool `json:"ownKey"`
+	// Trusted is set for this machine's key and keys on the trusted list;
+	// TrustedName is the name the key was trusted under.
+	Trusted     bool   `json:"trusted"`
+	TrustedName string `json:"trustedName,omitempty"`
+}
+
+// TrustedKey is a teammate's signing key, added after comparing key IDs.
+type TrustedKey struct {
+	KeyID string    `json:"keyId"`
+	Name  string    `json:"name"`
+	Added time.Time `json:"added"`
+}
+
+func keyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+func (m *Manager) signingKeyPath() string {
+	return filepath.Join(m.keysDir, signingKeyFile)
+}
+
+// migrateSigningKey moves a key left in the profiles folder by an older
+// version to the keys folder, where the web UI's file server cannot reach it.
+func (m *Manager) migrateSigningKey() {
+	legacy := filepath.Join(m.profilesDir, legacyKeysDir, signingKeyFile)
+	data, err := os.ReadFile(legacy)
+	if err != nil || m.keysDir == "" {
+		return
+	}
+	signingKeyMu.Lock()
+	defer signingKeyMu.Unlock()
+	if _, err := os.Stat(m.signingKeyPath()); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(m.keysDir, 0o700); err != nil {
+			return
+		}
+		if err := os.WriteFile(m.signingKeyPath(), data, 0o600); err != nil {
+			return
+		}
+	}
+	os.Remove(legacy)
+	os.Remove(filepath.Dir(legacy))
+}
+
+// signingKey loads the local ed25519 key, generating it on first use.
+func (m *Manager) signingKey() (ed25519.PrivateKey, error) {
+	if m.keysDir == "" {
+		return nil, fmt.Errorf("%w: no folder for the signing key", ErrInvalid)
+	}
+	signingKeyMu.Lock()
+	defer signingKeyMu.Unlock()
+	path := m.signingKeyPath()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(priv)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+		return priv, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: %w key file", path, ErrUnreadable)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an ed25519 key", path)
+	}
+	return priv, nil
+}
+
+// SigningKey returns the public half of the local export signing key,
+// generating the key if there is none yet.
+func (m *Manager) SigningKey() (SigningKey, error) {
+	priv, err := m.signingKey()
+	if err != nil {
+		return SigningKey{}, err
+	}
+	pub := priv.Public().(ed25519.PublicKey)
+	return SigningKey{KeyID: keyID(pub), PublicKey: base64.StdEncoding.EncodeToString(pub)}, nil
+}
+
+// TrustedKeys lists the keys whose archives are shown as from a known signer.
+func (m *Manager) TrustedKeys() ([]TrustedKey, error) {
+	signingKeyMu.Lock()
+	defer signingKeyMu.Unlock()
+	return m.readTrustedKeys()
+}
+
+func (m *Manager) readTrustedKeys() ([]TrustedKey, error) {
+	keys := []TrustedKey{}
+	if m.keysDir == "" {
+		return keys, nil
+	}
+	data, err := os.ReadFile(filepath.Join(m.keysDir, trustedKeysFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("%s: %w", trustedKeysFile, ErrUnreadable)
+	}
+	return keys, nil
+}
+
+func (m *Manager) writeTrustedKeys(keys []TrustedKey) error {
+	if m.keysDir == "" {
+		return fmt.Errorf("%w: no folder for trusted keys", ErrInvalid)
+	}
+	data, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.keysDir, 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.keysDir, trustedKeysFile), data, 0o600)
+}
+
+// TrustKey adds a key ID to the trusted list under name, or renames it if it
+// is already there.
+func (m *Manager) TrustKey(id, name string) error {
+	id, name = strings.ToLower(strings.TrimSpace(id)), strings.TrimSpace(name)
+	if b, err := hex.DecodeString(id); err != nil || len(b) != 8 {
+		return fmt.Errorf("%w: key ID must be 16 hex digits", ErrInvalid)
+	}
+	if name == "" {
+		return fmt.Errorf("%w: name required", ErrInvalid)
+	}
+	signingKeyMu.Lock()
+	defer signingKeyMu.Unlock()
+	keys, err := m.readTrustedKeys()
+	if err != nil {
+		return err
+	}
+	for i := range keys {
+		if keys[i].KeyID == id {
+			keys[i].Name = name
+			return m.writeTrustedKeys(keys)
+		}
+	}
+	return m.writeTrustedKeys(append(keys, TrustedKey{KeyID: id, Name: name, Added: time.Now().UTC()}))
+}
+
+// DistrustKey removes a key ID from the trusted list.
+func (m *Manager) DistrustKey(id string) error {
+	id = strings.ToLower(strings.TrimSpace(id))
+	signingKeyMu.Lock()
+	defer signingKeyMu.Unlock()
+	keys, err := m.readTrustedKeys()
+	if err != nil {
+		return err
+	}
+	for i := range keys {
+		if keys[i].KeyID == id {
+			return m.writeTrustedKeys(append(keys[:i], keys[i+1:]...))
+		}
+	}
+	return fmt.Errorf("key %s %w", id, ErrNotFound)
+}
+
+func signManifest(priv ed25519.PrivateKey, manifest []byte) ([]byte, error) {
+	return json.MarshalIndent(Signature{
+		Algorithm: "ed25519",
+		PublicKey: base64.StdEncoding.EncodeToString(priv.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, manifest)),
+	}, "", "  ")
+}
+
+// VerifyArchive checks a zip against its manifest without writing anything.
+func (m *Manager) VerifyArchive(r io.ReaderAt, size int64) (ArchiveVerification, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return ArchiveVerification{}, fmt.Errorf("%w: not a zip archive: %v", ErrInvalid, err)
+	}
+	return m.verifyArchive(zr.File)
+}
+
+// verifyArchive checks the signature on manifest.json, if any, and that every
+// other file in the archive is listed in it with the same size and SHA-256.
+func (m *Manager) verifyArchive(files []*zip.File) (ArchiveVerification, error) {
+	var v ArchiveVerification
+	byName := map[string]*zip.File{}
+	for _, f := range files {
+		if !f.FileInfo().IsDir() {
+			byName[archivePath(f.Name)] = f
+		}
+	}
+	mf, ok := byName[manifestName]
+	if !ok {
+		if _, signed := byName[signatureName]; signed {
+			return v, fmt.Errorf("%w: signature without a manifest", ErrTampered)
+		}
+		return v, nil
+	}
+	data, err := readArchiveFile(mf, 64<<20)
+	if err != nil {
+		return v, err
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return v, fmt.Errorf("%w: manifest: %v", ErrTampered, err)
+	}
+	v = ArchiveVerification{Manifest: true, Profile: manifest.Profile, Created: manifest.Created, Files: len(manifest.Files)}
+
+	if sf, ok := byName[signatureName]; ok {
+		raw, err := readArchiveFile(sf, 1<<16)
+		if err != nil {
+			return v, err
+		}
+		var sig Signature
+		if err := json.Unmarshal(raw, &sig); err != nil || sig.Algorithm != "ed25519" {
+			return v, fmt.Errorf("%w: unsupported signature", ErrTampered)
+		}
+		pub, err1 := base64.StdEncoding.DecodeString(sig.PublicKey)
+		s, err2 := base64.StdEncoding.DecodeString(sig.Signature)
+		if err1 != nil || err2 != nil || len(pub) != ed25519.PublicKeySize || !ed25519.Verify(pub, data, s) {
+			return v, fmt.Errorf("%w: bad signature", ErrTampered)
+		}
+		// The signer name is only worth showing once the signature covers it.
+		v.Signed = true
+		v.Signer = manifest.Signer
+		v.KeyID = keyID(pub)
+		// Only compare with a key that exists; verifying must not create one.
+		if _, err := os.Stat(m.signingKeyPath()); err == nil && m.keysDir != "" {
+			if own, err := m.SigningKey(); err == nil {
+				v.OwnKey = own.PublicKey == sig.PublicKey
+			}
+		}
+		v.Trusted = v.OwnKey
+		if keys, err := m.TrustedKeys(); err == nil {
+			for _, k := range keys {
+				if k.KeyID == v.KeyID {
+					v.Trusted, v.TrustedName = true, k.Name
+				}
+			}
+		}
+	}
+
+	listed := map[string]bool{manifestName: true, signatureName: true}
+	for _, mfile := range manifest.Files {
+		listed[mfile.Path] = true
+		f, ok := byName[mfile.Path]
+		if !ok {
+			return v, fmt.Errorf("%w: %s is missing", ErrTampered, mfile.Path)
+		}
+		if f.UncompressedSize64 != uint64(mfile.Size) {
+			return v, fmt.Errorf("%w: %s has the wrong size", ErrTampered, mfile.Path)
+		}
+		sum, err := hashArchiveFile(f)
+		if err != nil {
+			return v, err
+		}
+		if sum != mfile.SHA256 {
+			return v, fmt.Errorf("%w: %s has the wrong checksum", ErrTampered, mfile.Path)
+		}
+	}
+	for name := range byName {
+		if !listed[name] {
+			return v, fmt.Errorf("%w: %s is not in the manifest", ErrTampered, name)
+		}
+	}
+	return v, nil
+}
+
+func readArchiveFile(f *zip.File, limit int64) ([]byte, error) {
+	if f.UncompressedSize64 > uint64(limit) {
+		return nil, fmt.Errorf("%w: %s is too large", ErrInvalid, f.Name)
+	}
+	in, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	defer in.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(in, limit)); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func hashArchiveFile(f *zip.File) (string, error) {
+	in, err := f.Open()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", f.Name, err)
+	}
+	defer in.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, io.LimitReader(in, int64(f.UncompressedSize64)+1)); err != nil {
+		return "", fmt.Errorf("%w: %s: %v", ErrTampered, f.Name, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,169 @@
+package core
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+var testExportFiles = []string{
+	"P/ManualSave-0/sav.dat", "save data",
+	"P/ManualSave-0/metadata.9.json", "{}",
+}
+
+// testManifest lists name, content pairs the way NewExport would.
+func testManifest(files ...string) Manifest {
+	m := Manifest{Version: manifestVersion, Profile: "P", Created: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	for i := 0; i < len(files); i += 2 {
+		sum := sha256.Sum256([]byte(files[i+1]))
+		m.Files = append(m.Files, ManifestFile{Path: files[i], Size: int64(len(files[i+1])), SHA256: hex.EncodeToString(sum[:])})
+	}
+	return m
+}
+
+func manifestBytes(t *testing.T, m Manifest) []byte {
+	t.Helper()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func signedBy(t *testing.T, key ed25519.PrivateKey, manifest []byte) []byte {
+	t.Helper()
+	sig, err := signManifest(key, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// testExport zips files with manifest and, when set, its signature.
+func testExport(t *testing.T, manifest, sig []byte, files ...string) []*zip.File {
+	t.Helper()
+	entries := append([]string{}, files...)
+	entries = append(entries, manifestName, string(manifest))
+	if sig != nil {
+		entries = append(entries, signatureName, string(sig))
+	}
+	return testZip(t, zipFiles(t, entries...))
+}
+
+func newTestKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+func TestVerifyArchiveTampered(t *testing.T) {
+	m := &Manager{keysDir: t.TempDir()}
+	key := newTestKey(t)
+	valid := manifestBytes(t, testManifest(testExportFiles...))
+	wrongHash := testManifest(testExportFiles...)
+	wrongHash.Files[0].SHA256 = wrongHash.Files[1].SHA256
+	forged := testManifest(testExportFiles...)
+	forged.Signer = "Someone Else"
+	var otherKey Signature
+	if err := json.Unmarshal(signedBy(t, key, valid), &otherKey); err != nil {
+		t.Fatal(err)
+	}
+	otherKey.PublicKey = base64.StdEncoding.EncodeToString(newTestKey(t).Public().(ed25519.PublicKey))
+	otherSig, _ := json.Marshal(otherKey)
+
+	tests := []struct {
+		name  string
+		files []*zip.File
+	}{
+		{"hash mismatch", testExport(t, manifestBytes(t, wrongHash), nil, testExportFiles...)},
+		{"file not in manifest", testExport(t, valid, nil, append(testExportFiles, "P/ManualSave-0/extra.dll", "x")...)},
+		{"missing file", testExport(t, valid, nil, testExportFiles[:2]...)},
+		{"manifest changed after signing", testExport(t, manifestBytes(t, forged), signedBy(t, key, valid), testExportFiles...)},
+		{"signature claiming another key", testExport(t, valid, otherSig, testExportFiles...)},
+		{"signature without manifest", testZip(t, zipFiles(t, append(testExportFiles, signatureName, string(signedBy(t, key, valid)))...))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.verifyArchive(tt.files); !errors.Is(err, ErrTampered) {
+				t.Fatalf("err = %v, want ErrTampered", err)
+			}
+		})
+	}
+}
+
+func TestVerifyArchiveUnsigned(t *testing.T) {
+	m := &Manager{keysDir: t.TempDir()}
+	v, err := m.verifyArchive(testExport(t, manifestBytes(t, testManifest(testExportFiles...)), nil, testExportFiles...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Manifest || v.Signed || v.Trusted || v.Files != 2 || v.Profile != "P" {
+		t.Fatalf("verification = %+v", v)
+	}
+	v, err = m.verifyArchive(testZip(t, zipFiles(t, testExportFiles...)))
+	if err != nil || v.Manifest {
+		t.Fatalf("archive without manifest: %+v, %v", v, err)
+	}
+}
+
+func TestVerifyArchiveTrust(t *testing.T) {
+	m := &Manager{keysDir: t.TempDir()}
+	key := newTestKey(t)
+	man := testManifest(testExportFiles...)
+	man.Signer = "Judy"
+	data := manifestBytes(t, man)
+	files := testExport(t, data, signedBy(t, key, data), testExportFiles...)
+
+	v, err := m.verifyArchive(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := keyID(key.Public().(ed25519.PublicKey))
+	if !v.Signed || v.Signer != "Judy" || v.KeyID != id || v.Trusted || v.OwnKey {
+		t.Fatalf("untrusted key: %+v", v)
+	}
+
+	if err := m.TrustKey(id, "Judy A."); err != nil {
+		t.Fatal(err)
+	}
+	v, err = m.verifyArchive(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Trusted || v.TrustedName != "Judy A." || v.OwnKey {
+		t.Fatalf("trusted key: %+v", v)
+	}
+
+	if err := m.DistrustKey(id); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := m.verifyArchive(files); v.Trusted {
+		t.Fatalf("distrusted key still trusted: %+v", v)
+	}
+}
+
+func TestVerifyArchiveOwnKey(t *testing.T) {
+	m := &Manager{keysDir: t.TempDir()}
+	own, err := m.signingKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := manifestBytes(t, testManifest(testExportFiles...))
+	v, err := m.verifyArchive(testExport(t, data, signedBy(t, own, data), testExportFiles...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.OwnKey || !v.Trusted {
+		t.Fatalf("own key: %+v", v)
+	}
+}
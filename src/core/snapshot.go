@@ -0,0 +1,443 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const snapshotsDirName = ".snapshots"
+
+const (
+	snapshotManual     = "manual"
+	snapshotPreRestore = "pre-restore"
+)
+
+type Snapshot struct {
+	ID      string         `json:"id"`
+	Profile string         `json:"profile"`
+	Name    string         `json:"name"`
+	Kind    string         `json:"kind"`
+	Created time.Time      `json:"created"`
+	Pinned  bool           `json:"pinned"`
+	Size    int64          `json:"size"`
+	Count   int            `json:"count"`
+	Files   []SnapshotFile `json:"files,omitempty"`
+}
+
+type SnapshotFile struct {
+	Path   string   `json:"path"`
+	Size   int64    `json:"size"`
+	SHA256 string   `json:"sha256"`
+	Chunks []string `json:"chunks,omitempty"`
+}
+
+type SnapshotDiff struct {
+	From         string   `json:"from"`
+	To           string   `json:"to"`
+	SavesAdded   []string `json:"savesAdded"`
+	SavesRemoved []string `json:"savesRemoved"`
+	SavesChanged []string `json:"savesChanged"`
+	FilesAdded   []string `json:"filesAdded"`
+	FilesRemoved []string `json:"filesRemoved"`
+	FilesChanged []string `json:"filesChanged"`
+}
+
+func snapshotRoot(profilesDir, profile string) string {
+	return filepath.Join(profilesDir, snapshotsDirName, profile)
+}
+
+func validSnapshotID(id string) bool {
+	return id != "" && !strings.ContainsAny(id, `/\.:`)
+}
+
+func createSnapshot(profilesDir, profile, name, kind string, progress progressFunc) (Snapshot, error) {
+	src := filepath.Join(profilesDir, profile)
+	if !dirExists(src) {
+		return Snapshot{}, fmt.Errorf("profile %s %w", profile, ErrNotFound)
+	}
+	now := time.Now()
+	snap := Snapshot{
+		ID:      now.Format("20060102_150405"),
+		Profile: profile,
+		Name:    strings.TrimSpace(name),
+		Kind:    kind,
+		Created: now,
+	}
+	root := snapshotRoot(profilesDir, profile)
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return Snapshot{}, err
+	}
+	// Mkdir fails for an existing ID, so two snapshots in the same second
+	// cannot claim the same folder.
+	dir := filepath.Join(root, snap.ID)
+	for i := 2; ; i++ {
+		err := os.Mkdir(dir, 0o755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return Snapshot{}, err
+		}
+		snap.ID = fmt.Sprintf("%s_%d", now.Format("20060102_150405"), i)
+		dir = filepath.Join(root, snap.ID)
+	}
+	var files []string
+	err := walkProfileFiles(src, func(rel, full string) error {
+		files = append(files, rel)
+		return nil
+	})
+	store := newBlobStore(profilesDir)
+	defer lockStore(profilesDir, false)()
+	for i := 0; err == nil && i < len(files); i++ {
+		var f SnapshotFile
+		f, err = store.putFile(filepath.Join(src, filepath.FromSlash(files[i])))
+		f.Path = files[i]
+		snap.Files = append(snap.Files, f)
+		snap.Size += f.Size
+		progress.report(i+1, len(files))
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return Snapshot{}, err
+	}
+	snap.Count = len(snap.Files)
+	if err := writeSnapshotMeta(dir, snap); err != nil {
+		os.RemoveAll(dir)
+		return Snapshot{}, err
+	}
+	return snap, nil
+}
+
+// walkProfileFiles calls fn for every regular file in a profile with its
+// slash-separated path relative to the profile directory.
+func walkProfileFiles(base string, fn func(rel, full string) error) error {
+	return filepath.WalkDir(base, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(base, p)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), p)
+	})
+}
+
+func hashFile(p string) (SnapshotFile, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return SnapshotFile{}, err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return SnapshotFile{}, err
+	}
+	return SnapshotFile{Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func currentFiles(profileDir string) ([]SnapshotFile, error) {
+	var files []SnapshotFile
+	err := walkProfileFiles(profileDir, func(rel, full string) error {
+		f, err := hashFile(full)
+		if err != nil {
+			return err
+		}
+		f.Path = rel
+		files = append(files, f)
+		return nil
+	})
+	return files, err
+}
+
+func writeSnapshotMeta(dir string, snap Snapshot) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "snapshot.json"), data, 0o644)
+}
+
+func loadSnapshot(profilesDir, profile, id string) (Snapshot, error) {
+	if !validSnapshotID(id) {
+		return Snapshot{}, fmt.Errorf("%w: invalid snapshot id", ErrInvalid)
+	}
+	data, err := os.ReadFile(filepath.Join(snapshotRoot(profilesDir, profile), id, "snapshot.json"))
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("snapshot %s %w", id, ErrNotFound)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return Snapshot{}, err
+	}
+	return snap, nil
+}
+
+// listSnapshots returns the snapshots of a profile, newest first, without their
+// file lists.
+func listSnapshots(profilesDir, profile string) []Snapshot {
+	entries, err := os.ReadDir(snapshotRoot(profilesDir, profile))
+	if err != nil {
+		return []Snapshot{}
+	}
+	res := []Snapshot{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		snap, err := loadSnapshot(profilesDir, profile, e.Name())
+		if err != nil {
+			continue
+		}
+		snap.Files = nil
+		res = append(res, snap)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Created.After(res[j].Created) })
+	return res
+}
+
+// moveSnapshots hands a profile's snapshots to another profile name, moving
+// them or, for a clone, copying them; blobs stay shared in the store.
+func moveSnapshots(profilesDir, from, to string, copy bool) error {
+	src, dest := snapshotRoot(profilesDir, from), snapshotRoot(profilesDir, to)
+	if !dirExists(src) {
+		return nil
+	}
+	// Hold off garbage collection while neither folder lists the blobs.
+	defer lockStore(profilesDir, false)()
+	var err error
+	if copy {
+		err = copyDir(src, dest)
+	} else {
+		err = os.Rename(src, dest)
+	}
+	if err != nil {
+		return err
+	}
+	for _, s := range listSnapshots(profilesDir, to) {
+		snap, err := loadSnapshot(profilesDir, to, s.ID)
+		if err != nil {
+			return err
+		}
+		snap.Profile = to
+		if err := writeSnapshotMeta(filepath.Join(dest, snap.ID), snap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteSnapshot(profilesDir, profile, id string) error {
+	snap, err := loadSnapshot(profilesDir, profile, id)
+	if err != nil {
+		return err
+	}
+	if snap.Pinned {
+		return fmt.Errorf("%w: %s", ErrPinned, id)
+	}
+	return os.RemoveAll(filepath.Join(snapshotRoot(profilesDir, profile), id))
+}
+
+func pinSnapshot(profilesDir, profile, id string, pinned bool) error {
+	snap, err := loadSnapshot(profilesDir, profile, id)
+	if err != nil {
+		return err
+	}
+	snap.Pinned = pinned
+	return writeSnapshotMeta(filepath.Join(snapshotRoot(profilesDir, profile), id), snap)
+}
+
+// restoreSnapshot replaces the contents of the profile folder with the snapshot.
+// The folder itself is kept so an active junction stays valid, and the current
+// contents are snapshotted first so a restore can itself be undone.
+func restoreSnapshot(profilesDir, profile, id string, progress progressFunc) (Snapshot, error) {
+	snap, err := loadSnapshot(profilesDir, profile, id)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	pre, err := createSnapshot(profilesDir, profile, "Before restoring "+snap.ID, snapshotPreRestore, nil)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("could not snapshot current state: %w", err)
+	}
+	base := filepath.Join(profilesDir, profile)
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(base, e.Name())); err != nil {
+			return Snapshot{}, fmt.Errorf("%v; previous state kept in snapshot %s", err, pre.ID)
+		}
+	}
+	for i, f := range snap.Files {
+		progress.report(i, len(snap.Files))
+		dest := filepath.Join(base, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return Snapshot{}, err
+		}
+		if err := restoreSnapshotFile(profilesDir, profile, snap, f, dest); err != nil {
+			return Snapshot{}, fmt.Errorf("%v; previous state kept in snapshot %s", err, pre.ID)
+		}
+	}
+	progress.report(len(snap.Files), len(snap.Files))
+	return pre, nil
+}
+
+// restoreSnapshotFile writes one file of a snapshot to dest.
+func restoreSnapshotFile(profilesDir, profile string, snap Snapshot, f SnapshotFile, dest string) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	err = writeSnapshotFile(out, profilesDir, profile, snap, f)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeSnapshotFile streams one file of a snapshot from the blob store or, for
+// snapshots not yet migrated, from their data/ copy.
+func writeSnapshotFile(w io.Writer, profilesDir, profile string, snap Snapshot, f SnapshotFile) error {
+	legacy := filepath.Join(snapshotRoot(profilesDir, profile), snap.ID, "data")
+	if !dirExists(legacy) {
+		return newBlobStore(profilesDir).writeTo(w, f)
+	}
+	in, err := os.Open(filepath.Join(legacy, filepath.FromSlash(f.Path)))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = io.Copy(w, in)
+	return err
+}
+
+func diffFiles(from, to []SnapshotFile) SnapshotDiff {
+	d := SnapshotDiff{
+		SavesAdded: []string{}, SavesRemoved: []string{}, SavesChanged: []string{},
+		FilesAdded: []string{}, FilesRemoved: []string{}, FilesChanged: []string{},
+	}
+	old := map[string]SnapshotFile{}
+	for _, f := range from {
+		old[f.Path] = f
+	}
+	cur := map[string]SnapshotFile{}
+	for _, f := range to {
+		cur[f.Path] = f
+	}
+	oldSaves, curSaves, touched := map[string]bool{}, map[string]bool{}, map[string]bool{}
+	for p, f := range cur {
+		curSaves[saveOf(p)] = true
+		o, ok := old[p]
+		switch {
+		case !ok:
+			d.FilesAdded = append(d.FilesAdded, p)
+			touched[saveOf(p)] = true
+		case o.SHA256 != f.SHA256:
+			d.FilesChanged = append(d.FilesChanged, p)
+			touched[saveOf(p)] = true
+		}
+	}
+	for p := range old {
+		oldSaves[saveOf(p)] = true
+		if _, ok := cur[p]; !ok {
+			d.FilesRemoved = append(d.FilesRemoved, p)
+			touched[saveOf(p)] = true
+		}
+	}
+	for s := range touched {
+		switch {
+		case !oldSaves[s]:
+			d.SavesAdded = append(d.SavesAdded, s)
+		case !curSaves[s]:
+			d.SavesRemoved = append(d.SavesRemoved, s)
+		default:
+			d.SavesChanged = append(d.SavesChanged, s)
+		}
+	}
+	for _, l := range [][]string{d.SavesAdded, d.SavesRemoved, d.SavesChanged, d.FilesAdded, d.FilesRemoved, d.FilesChanged} {
+		sort.Strings(l)
+	}
+	return d
+}
+
+// saveOf maps a profile-relative file path to the save folder it belongs to;
+// loose files at the profile root map to themselves.
+func saveOf(rel string) string {
+	if i := strings.Index(rel, "/"); i >= 0 {
+		return rel[:i]
+	}
+	return path.Clean(rel)
+}
+
+// Snapshots lists a profile's snapshots, newest first, without file lists.
+func (m *Manager) Snapshots(profile string) []Snapshot {
+	return listSnapshots(m.profilesDir, SanitizeName(profile))
+}
+
+// DeleteSnapshot removes an unpinned snapshot and frees store data only it used.
+func (m *Manager) DeleteSnapshot(profile, id string) error {
+	profile = SanitizeName(profile)
+	if profile == "" || id == "" {
+		return fmt.Errorf("%w: profile and id required", ErrInvalid)
+	}
+	if err := deleteSnapshot(m.profilesDir, profile, id); err != nil {
+		return err
+	}
+	if _, err := newBlobStore(m.profilesDir).gc(m.profilesDir); err != nil {
+		log.Printf("blob store gc failed: %v", err)
+	}
+	return nil
+}
+
+func (m *Manager) PinSnapshot(profile, id string, pinned bool) error {
+	profile = SanitizeName(profile)
+	if profile == "" || id == "" {
+		return fmt.Errorf("%w: profile and id required", ErrInvalid)
+	}
+	return pinSnapshot(m.profilesDir, profile, id, pinned)
+}
+
+// DiffSnapshot compares snapshot id with snapshot against or, when against is
+// empty, with the profile as it is now.
+func (m *Manager) DiffSnapshot(profile, id, against string) (SnapshotDiff, error) {
+	profile = SanitizeName(profile)
+	if profile == "" || id == "" {
+		return SnapshotDiff{}, fmt.Errorf("%w: profile and id required", ErrInvalid)
+	}
+	from, err := loadSnapshot(m.profilesDir, profile, id)
+	if err != nil {
+		return SnapshotDiff{}, err
+	}
+	to := "current"
+	var toFiles []SnapshotFile
+	if against != "" {
+		snap, err := loadSnapshot(m.profilesDir, profile, against)
+		if err != nil {
+			return SnapshotDiff{}, err
+		}
+		to, toFiles = snap.ID, snap.Files
+	} else {
+		toFiles, err = currentFiles(filepath.Join(m.profilesDir, profile))
+		if err != nil {
+			return SnapshotDiff{}, err
+		}
+	}
+	diff := diffFiles(from.Files, toFiles)
+	diff.From, diff.To = from.ID, to
+	return diff, nil
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestCreateSnapshotConcurrentIDs(t *testing.T) {
+	profilesDir := t.TempDir()
+	save := filepath.Join(profilesDir, "P", "ManualSave-0")
+	if err := os.MkdirAll(save, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(save, "sav.dat"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	const n = 8
+	ids := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			snap, err := createSnapshot(profilesDir, "P", "", snapshotManual, nil)
+			ids[i], errs[i] = snap.ID, err
+		}(i)
+	}
+	wg.Wait()
+	seen := map[string]bool{}
+	for i, id := range ids {
+		if errs[i] != nil {
+			t.Fatalf("snapshot %d: %v", i, errs[i])
+		}
+		if seen[id] {
+			t.Fatalf("ID %s used twice: %v", id, ids)
+		}
+		seen[id] = true
+	}
+}
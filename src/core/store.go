@@ -0,0 +1,261 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	storeDirName   = ".store"
+	storeChunkSize = 4 << 20
+)
+
+// storeMu keeps garbage collection from deleting a blob that a snapshot being
+// written is about to reference.
+var storeMu sync.RWMutex
+
+// lockStore takes storeMu and the store's lock file, so that garbage
+// collection in another process waits too. Writers of snapshots share the
+// lock; gc takes it exclusively. It returns the function that releases both.
+func lockStore(profilesDir string, exclusive bool) func() {
+	if exclusive {
+		storeMu.Lock()
+	} else {
+		storeMu.RLock()
+	}
+	unlock := lockProfilesFile(profilesDir, storeLockFile, exclusive)
+	return func() {
+		unlock()
+		if exclusive {
+			storeMu.Unlock()
+		} else {
+			storeMu.RUnlock()
+		}
+	}
+}
+
+// blobStore keeps file contents as SHA-256 named chunks under profilesDir/.store.
+// Snapshots reference chunks from their manifests, so identical screenshots and
+// sav.dat files are only stored once no matter how many snapshots contain them.
+type blobStore struct {
+	root string
+}
+
+type StoreStats struct {
+	Blobs   int   `json:"blobs"`
+	Bytes   int64 `json:"bytes"`
+	Removed int   `json:"removed,omitempty"`
+	Freed   int64 `json:"freed,omitempty"`
+}
+
+func newBlobStore(profilesDir string) *blobStore {
+	return &blobStore{root: filepath.Join(profilesDir, storeDirName)}
+}
+
+func (b *blobStore) blobPath(hash string) string {
+	return filepath.Join(b.root, "blobs", hash[:2], hash)
+}
+
+// putFile stores the file at path and returns its size, whole-file hash and
+// chunk list. Callers must hold storeMu for reading.
+func (b *blobStore) putFile(path string) (SnapshotFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SnapshotFile{}, err
+	}
+	defer f.Close()
+	whole := sha256.New()
+	res := SnapshotFile{Chunks: []string{}}
+	buf := make([]byte, storeChunkSize)
+	for {
+		n, err := io.ReadFull(f, buf)
+		if n > 0 {
+			chunk := buf[:n]
+			whole.Write(chunk)
+			sum := sha256.Sum256(chunk)
+			hash := hex.EncodeToString(sum[:])
+			if err := b.putChunk(hash, chunk); err != nil {
+				return SnapshotFile{}, err
+			}
+			res.Chunks = append(res.Chunks, hash)
+			res.Size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return SnapshotFile{}, err
+		}
+	}
+	res.SHA256 = hex.EncodeToString(whole.Sum(nil))
+	return res, nil
+}
+
+func (b *blobStore) putChunk(hash string, data []byte) error {
+	dest := b.blobPath(hash)
+	if _, err := os.Stat(dest); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), hash+".tmp*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// writeTo streams a stored file to w, checking every chunk against its hash.
+func (b *blobStore) writeTo(w io.Writer, f SnapshotFile) error {
+	for _, hash := range f.Chunks {
+		data, err := os.ReadFile(b.blobPath(hash))
+		if err != nil {
+			return fmt.Errorf("%s: missing chunk %s", f.Path, hash)
+		}
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != hash {
+			return fmt.Errorf("%s: chunk %s is corrupted", f.Path, hash)
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *blobStore) stats() StoreStats {
+	var st StoreStats
+	_ = filepath.WalkDir(filepath.Join(b.root, "blobs"), func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			st.Blobs++
+			st.Bytes += info.Size()
+		}
+		return nil
+	})
+	return st
+}
+
+// gc removes chunks no snapshot manifest refers to, including those of
+// profiles in the trash.
+func (b *blobStore) gc(profilesDir string) (StoreStats, error) {
+	defer lockStore(profilesDir, true)()
+	live := map[string]bool{}
+	manifests, _ := filepath.Glob(filepath.Join(profilesDir, snapshotsDirName, "*", "*", "snapshot.json"))
+	// Snapshots of a deleted profile wait in its trash item.
+	trashed, _ := filepath.Glob(filepath.Join(trashRoot(profilesDir), "*", trashSnapshotsDir, "*", "snapshot.json"))
+	for _, m := range append(manifests, trashed...) {
+		data, err := os.ReadFile(m)
+		if err != nil {
+			return StoreStats{}, err
+		}
+		var snap Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			return StoreStats{}, fmt.Errorf("%s: %w", m, err)
+		}
+		for _, f := range snap.Files {
+			for _, h := range f.Chunks {
+				live[h] = true
+			}
+		}
+	}
+	var st StoreStats
+	err := filepath.WalkDir(filepath.Join(b.root, "blobs"), func(p string, d os.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if live[d.Name()] {
+			st.Blobs++
+			st.Bytes += info.Size()
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		st.Removed++
+		st.Freed += info.Size()
+		return nil
+	})
+	return st, err
+}
+
+// migrateSnapshots moves snapshots that still carry a plain data/ copy into
+// the blob store.
+func migrateSnapshots(profilesDir string) {
+	dirs, _ := filepath.Glob(filepath.Join(profilesDir, snapshotsDirName, "*", "*", "data"))
+	if len(dirs) == 0 {
+		return
+	}
+	store := newBlobStore(profilesDir)
+	defer lockStore(profilesDir, false)()
+	start := time.Now()
+	for _, data := range dirs {
+		dir := filepath.Dir(data)
+		if err := migrateSnapshot(store, dir); err != nil {
+			log.Printf("snapshot %s not migrated: %v", dir, err)
+		}
+	}
+	log.Printf("migrated %d snapshots to the blob store in %s", len(dirs), time.Since(start).Round(time.Second))
+}
+
+func migrateSnapshot(store *blobStore, dir string) error {
+	raw, err := os.ReadFile(filepath.Join(dir, "snapshot.json"))
+	if err != nil {
+		return err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(raw, &snap); err != nil {
+		return err
+	}
+	data := filepath.Join(dir, "data")
+	for i, f := range snap.Files {
+		stored, err := store.putFile(filepath.Join(data, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return err
+		}
+		if stored.SHA256 != f.SHA256 {
+			return fmt.Errorf("%s does not match its recorded hash", f.Path)
+		}
+		snap.Files[i].Chunks = stored.Chunks
+	}
+	if err := writeSnapshotMeta(dir, snap); err != nil {
+		return err
+	}
+	return os.RemoveAll(data)
+}
+
+func (m *Manager) StoreStats() StoreStats {
+	return newBlobStore(m.profilesDir).stats()
+}
+
+// CollectGarbage removes store data no snapshot refers to any more.
+func (m *Manager) CollectGarbage() (StoreStats, error) {
+	return newBlobStore(m.profilesDir).gc(m.profilesDir)
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// TimelinePoint is one save's place in a playthrough, with the raw numbers
+// from its metadata.
+type TimelinePoint struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Modified   string   `json:"modified"`
+	PlayTime   float64  `json:"playTime"`
+	Level      float64  `json:"level"`
+	StreetCred float64  `json:"streetCred"`
+	Money      float64  `json:"money"`
+	Quest      QuestRef `json:"quest"`
+	// QuestsFinished counts the finished quests; NewlyFinished names those
+	// not finished at the previous point.
+	QuestsFinished int      `json:"questsFinished"`
+	NewlyFinished  []string `json:"newlyFinished"`
+	// Regressions says what went backwards since the previous point, which
+	// usually means the save belongs to another run or was loaded from an
+	// older one.
+	Regressions []string `json:"regressions,omitempty"`
+}
+
+// Timeline is a profile's saves ordered by play time.
+type Timeline struct {
+	Profile string          `json:"profile"`
+	Points  []TimelinePoint `json:"points"`
+	// Skipped lists saves whose metadata could not be read.
+	Skipped []string `json:"skipped"`
+}
+
+// Timeline reads every save of a profile and orders them by play time so the
+// progression of the playthrough can be charted.
+func (m *Manager) Timeline(profile string) (Timeline, error) {
+	profile = SanitizeName(profile)
+	if profile == "" {
+		return Timeline{}, fmt.Errorf("%w: profile required", ErrInvalid)
+	}
+	base := filepath.Join(m.profilesDir, profile)
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return Timeline{}, fmt.Errorf("profile %s %w", profile, ErrNotFound)
+	}
+	type parsed struct {
+		point    TimelinePoint
+		finished QuestList
+	}
+	var saves []parsed
+	tl := Timeline{Profile: profile, Points: []TimelinePoint{}, Skipped: []string{}}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(base, e.Name())
+		meta, err := parseMetadata(dir)
+		if err != nil {
+			tl.Skipped = append(tl.Skipped, e.Name())
+			continue
+		}
+		modified := ""
+		if info, err := e.Info(); err == nil {
+			modified = info.ModTime().Format("2006-01-02 15:04:05")
+		}
+		saves = append(saves, parsed{
+			point: TimelinePoint{
+				Name:       e.Name(),
+				Type:       classifySave(e.Name()),
+				Modified:   modified,
+				PlayTime:   meta.PlayTime,
+				Level:      meta.Level,
+				StreetCred: meta.StreetCred,
+				Money:      meta.Money,
+				Quest:      questRef(meta.TrackedQuestEntry),
+			},
+			finished: meta.FinishedQuests,
+		})
+	}
+	sort.SliceStable(saves, func(i, j int) bool {
+		if saves[i].point.PlayTime != saves[j].point.PlayTime {
+			return saves[i].point.PlayTime < saves[j].point.PlayTime
+		}
+		return saves[i].point.Modified < saves[j].point.Modified
+	})
+	var prev TimelinePoint
+	var prevFinished QuestList
+	for _, s := range saves {
+		p := s.point
+		p.QuestsFinished = len(s.finished)
+		p.NewlyFinished = []string{}
+		for _, q := range questsMissing(s.finished, prevFinished) {
+			title := q.Title
+			if title == "" {
+				title = q.Path
+			}
+			p.NewlyFinished = append(p.NewlyFinished, title)
+		}
+		if len(tl.Points) > 0 {
+			if p.Level < prev.Level {
+				p.Regressions = append(p.Regressions, fmt.Sprintf("level %g after %g", p.Level, prev.Level))
+			}
+			if p.StreetCred < prev.StreetCred {
+				p.Regressions = append(p.Regressions, fmt.Sprintf("street cred %g after %g", p.StreetCred, prev.StreetCred))
+			}
+			if dropped := questsMissing(prevFinished, s.finished); len(dropped) > 0 {
+				p.Regressions = append(p.Regressions, fmt.Sprintf("%d finished quests no longer finished", len(dropped)))
+			}
+		}
+		tl.Points = append(tl.Points, p)
+		prev, prevFinished = p, s.finished
+	}
+	return tl, nil
+}
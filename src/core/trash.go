@@ -0,0 +1,286 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	trashDirName  = ".trash"
+	trashItemFile = "trash.json"
+	// trashSnapshotsDir holds a deleted profile's snapshots next to its data.
+	trashSnapshotsDir = "snapshots"
+	// DefaultTrashDays is how long deleted items are kept when Options
+	// leaves TrashDays at zero.
+	DefaultTrashDays   = 30
+	trashPurgeInterval = 6 * time.Hour
+)
+
+// Kinds of trashed items.
+const (
+	TrashProfile = "profile"
+	TrashSave    = "save"
+)
+
+// TrashItem is a deleted profile or save waiting in profilesDir/.trash. Its
+// files sit in a "data" folder next to trash.json, and a profile's snapshots
+// in a "snapshots" folder.
+type TrashItem struct {
+	ID      string    `json:"id"`
+	Kind    string    `json:"kind"`
+	Profile string    `json:"profile"`
+	Name    string    `json:"name,omitempty"`
+	Origin  string    `json:"origin"`
+	Deleted time.Time `json:"deleted"`
+	Size    int64     `json:"size"`
+	// SaveMeta holds a save's tags and label so a restore brings them back.
+	SaveMeta *SaveMeta `json:"saveMeta,omitempty"`
+}
+
+func trashRoot(profilesDir string) string {
+	return filepath.Join(profilesDir, trashDirName)
+}
+
+func newTrashID() string {
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	return time.Now().Format("20060102_150405") + "_" + hex.EncodeToString(b)
+}
+
+// moveToTrash moves path, which must be inside profilesDir, into the trash.
+func moveToTrash(profilesDir, path string, item TrashItem) (TrashItem, error) {
+	rel, err := filepath.Rel(profilesDir, path)
+	if err != nil {
+		return TrashItem{}, err
+	}
+	item.ID = newTrashID()
+	item.Origin = filepath.ToSlash(rel)
+	item.Deleted = time.Now()
+	item.Size = dirSize(path)
+	dir := filepath.Join(trashRoot(profilesDir), item.ID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return TrashItem{}, err
+	}
+	if err := writeTrashItem(dir, item); err != nil {
+		_ = os.RemoveAll(dir)
+		return TrashItem{}, err
+	}
+	if err := os.Rename(path, filepath.Join(dir, "data")); err != nil {
+		_ = os.RemoveAll(dir)
+		return TrashItem{}, err
+	}
+	return item, nil
+}
+
+// trashSnapshots moves a trashed profile's snapshots into its trash item.
+func trashSnapshots(profilesDir, profile, id string) error {
+	src := snapshotRoot(profilesDir, profile)
+	if !dirExists(src) {
+		return nil
+	}
+	// Keep garbage collection from looking while the folder moves.
+	defer lockStore(profilesDir, false)()
+	return os.Rename(src, filepath.Join(trashRoot(profilesDir), id, trashSnapshotsDir))
+}
+
+// restoreTrashedSnapshots moves the snapshots kept in a trash item back under
+// profile, next to any it already has.
+func restoreTrashedSnapshots(profilesDir, dir, profile string) error {
+	src := filepath.Join(dir, trashSnapshotsDir)
+	entries, err := os.ReadDir(src)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer lockStore(profilesDir, false)()
+	dest := snapshotRoot(profilesDir, profile)
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.Rename(filepath.Join(src, e.Name()), filepath.Join(dest, e.Name())); err != nil {
+			return err
+		}
+		snap, err := loadSnapshot(profilesDir, profile, e.Name())
+		if err != nil || snap.Profile == profile {
+			continue
+		}
+		snap.Profile = profile
+		if err := writeSnapshotMeta(filepath.Join(dest, e.Name()), snap); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeTrashItem(dir string, item TrashItem) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, trashItemFile), data, 0o644)
+}
+
+func loadTrashItem(profilesDir, id string) (TrashItem, error) {
+	if !validSnapshotID(id) {
+		return TrashItem{}, fmt.Errorf("%w: invalid trash id", ErrInvalid)
+	}
+	data, err := os.ReadFile(filepath.Join(trashRoot(profilesDir), id, trashItemFile))
+	if err != nil {
+		return TrashItem{}, fmt.Errorf("trash item %s %w", id, ErrNotFound)
+	}
+	var item TrashItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return TrashItem{}, err
+	}
+	return item, nil
+}
+
+func dirSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// Trash lists deleted profiles and saves, newest first.
+func (m *Manager) Trash() []TrashItem {
+	entries, err := os.ReadDir(trashRoot(m.profilesDir))
+	if err != nil {
+		return []TrashItem{}
+	}
+	res := []TrashItem{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		if item, err := loadTrashItem(m.profilesDir, e.Name()); err == nil {
+			res = append(res, item)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Deleted.After(res[j].Deleted) })
+	return res
+}
+
+// RestoreTrash moves a deleted item back where it came from, or for a save
+// into the profile named by target when that is set. A profile gets its
+// snapshots back too. An item already in
+// the way is left alone and ErrExists returned.
+func (m *Manager) RestoreTrash(id, target string) (TrashItem, error) {
+	item, err := loadTrashItem(m.profilesDir, id)
+	if err != nil {
+		return TrashItem{}, err
+	}
+	origin := filepath.Join(m.profilesDir, filepath.FromSlash(item.Origin))
+	profile := item.Profile
+	if target = SanitizeName(target); target != "" {
+		profile = target
+		if item.Kind == TrashProfile {
+			origin = filepath.Join(m.profilesDir, target)
+		} else {
+			origin = filepath.Join(m.profilesDir, target, item.Name)
+		}
+	}
+	if !pointsIntoProfiles(origin, m.profilesDir) || strings.HasPrefix(filepath.Base(origin), ".") {
+		return TrashItem{}, fmt.Errorf("%w: bad origin %s", ErrInvalid, item.Origin)
+	}
+	if _, err := os.Stat(origin); err == nil {
+		return TrashItem{}, fmt.Errorf("%s %w", filepath.Base(origin), ErrExists)
+	}
+	if err := os.MkdirAll(filepath.Dir(origin), 0o755); err != nil {
+		return TrashItem{}, err
+	}
+	dir := filepath.Join(trashRoot(m.profilesDir), item.ID)
+	// Snapshots go first so that a failure leaves the profile in the trash to
+	// retry.
+	if item.Kind == TrashProfile {
+		if err := restoreTrashedSnapshots(m.profilesDir, dir, profile); err != nil {
+			return TrashItem{}, err
+		}
+	}
+	if err := os.Rename(filepath.Join(dir, "data"), origin); err != nil {
+		return TrashItem{}, err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("trash item %s restored but not removed: %v", item.ID, err)
+	}
+	if item.SaveMeta != nil {
+		sm := *item.SaveMeta
+		_ = editSaveMeta(m.profilesDir, profile, func(meta map[string]SaveMeta) { meta[item.Name] = sm })
+	}
+	if item.Kind == TrashProfile {
+		m.events.publish("profile", map[string]string{"profile": profile})
+	} else {
+		m.watcher.refresh(filepath.Join(m.profilesDir, profile))
+	}
+	return item, nil
+}
+
+// PurgeTrash deletes one trashed item for good, or every item when id is "".
+func (m *Manager) PurgeTrash(id string) (int, error) {
+	if id == "" {
+		return m.purgeTrashOlderThan(0)
+	}
+	if _, err := loadTrashItem(m.profilesDir, id); err != nil {
+		return 0, err
+	}
+	return 1, os.RemoveAll(filepath.Join(trashRoot(m.profilesDir), id))
+}
+
+func (m *Manager) purgeTrashOlderThan(age time.Duration) (int, error) {
+	n := 0
+	var errs []error
+	for _, item := range m.Trash() {
+		if time.Since(item.Deleted) < age {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(trashRoot(m.profilesDir), item.ID)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n++
+	}
+	return n, errors.Join(errs...)
+}
+
+// TrashDays is how many days deleted items are kept; negative keeps them
+// until purged by hand.
+func (m *Manager) TrashDays() int { return int(m.trashDays.Load()) }
+
+func (m *Manager) SetTrashDays(days int) {
+	if days == 0 {
+		days = DefaultTrashDays
+	}
+	m.trashDays.Store(int32(days))
+}
+
+// trashLoop purges expired items now and every few hours after.
+func (m *Manager) trashLoop() {
+	for {
+		if days := m.TrashDays(); days > 0 {
+			if n, err := m.purgeTrashOlderThan(time.Duration(days) * 24 * time.Hour); err != nil {
+				log.Printf("trash purge: %v", err)
+			} else if n > 0 {
+				log.Printf("trash purge removed %d items older than %d days", n, days)
+			}
+		}
+		time.Sleep(trashPurgeInterval)
+	}
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDeleteProfileTrashesSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	profilesDir := filepath.Join(dir, "profiles")
+	m, err := New(Options{ProfilesDir: profilesDir, GameSavePath: filepath.Join(dir, "saves"), KeysDir: filepath.Join(dir, "keys")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	save := filepath.Join(profilesDir, "P", "ManualSave-0")
+	if err := os.MkdirAll(save, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(save, "sav.dat"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := createSnapshot(profilesDir, "P", "", snapshotManual, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := m.DeleteProfile("P")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dirExists(snapshotRoot(profilesDir, "P")) {
+		t.Fatal("snapshots left behind by the deleted profile")
+	}
+	st, err := m.CollectGarbage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Removed != 0 || st.Blobs == 0 {
+		t.Fatalf("gc of trashed snapshots: %+v", st)
+	}
+
+	if _, err := m.RestoreTrash(item.ID, "Q"); err != nil {
+		t.Fatal(err)
+	}
+	snaps := listSnapshots(profilesDir, "Q")
+	if len(snaps) != 1 || snaps[0].ID != snap.ID || snaps[0].Profile != "Q" {
+		t.Fatalf("restored snapshots = %+v", snaps)
+	}
+	if dirExists(filepath.Join(trashRoot(profilesDir), item.ID)) {
+		t.Error("trash item not removed after restore")
+	}
+}
@@ -0,0 +1,39 @@
+package core
+
+type SaveInfo struct {
+	Name       string `json:"name"`
+	Modified   string `json:"modified"`
+	Type       string `json:"type"`
+	Screenshot string `json:"screenshot"`
+	Playtime   string `json:"playtime"`
+	Level      string `json:"level"`
+	Quest      string `json:"quest"`
+	QuestTitle string `json:"questTitle"`
+	Objective  string `json:"objective"`
+	Corrupt    bool   `json:"corrupt"`
+	Problem    string `json:"problem,omitempty"`
+	// Tags, Favorite and Label are the user's annotations from SaveMeta.
+	Tags     []string `json:"tags"`
+	Favorite bool     `json:"favorite"`
+	Label    string   `json:"label,omitempty"`
+}
+
+type SaveDetails struct {
+	Profile        string       `json:"profile"`
+	Name           string       `json:"name"`
+	Type           string       `json:"type"`
+	Modified       string       `json:"modified"`
+	Screenshot     string       `json:"screenshot"`
+	QuestTitle     string       `json:"questTitle"`
+	Objective      string       `json:"objective"`
+	PhantomLiberty bool         `json:"phantomLiberty"`
+	Metadata       SaveMetadata `json:"metadata"`
+}
+
+type metaSummary struct {
+	Playtime   string
+	Level      string
+	Quest      string
+	QuestTitle string
+	Objective  string
+}
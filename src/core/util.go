@@ -0,0 +1,16 @@
+package core
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// SanitizeName turns user input into a safe profile or save folder name.
+func SanitizeName(in string) string {
+	s := strings.TrimSpace(in)
+	// Leading dots would hide the folder or escape profilesDir via "..".
+	s = strings.TrimLeft(s, ".")
+	s = strings.ReplaceAll(s, string(filepath.Separator), "_")
+	s = strings.ReplaceAll(s, " ", "_")
+	return s
+}
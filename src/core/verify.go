@@ -0,0 +1,146 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"cybersaver/savfile"
+)
+
+const (
+	minSavSize      = 16 << 10
+	minMetadataSize = 64
+)
+
+type SaveProblem struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+type SaveCheck struct {
+	OK      bool      `json:"ok"`
+	Reason  string    `json:"reason,omitempty"`
+	Checked time.Time `json:"checked"`
+
+	fingerprint string
+}
+
+// integrityCache remembers verification results per save directory and only
+// re-verifies when the files in it change.
+type integrityCache struct {
+	mu      sync.Mutex
+	results map[string]SaveCheck
+}
+
+func newIntegrityCache() *integrityCache {
+	return &integrityCache{results: map[string]SaveCheck{}}
+}
+
+func (c *integrityCache) check(saveDir string) SaveCheck {
+	fp := saveFingerprint(saveDir)
+	c.mu.Lock()
+	res, ok := c.results[saveDir]
+	c.mu.Unlock()
+	if ok && res.fingerprint == fp {
+		return res
+	}
+	res = verifySave(saveDir)
+	res.fingerprint = fp
+	c.mu.Lock()
+	c.results[saveDir] = res
+	c.mu.Unlock()
+	return res
+}
+
+func (c *integrityCache) forget(saveDir string) {
+	c.mu.Lock()
+	delete(c.results, saveDir)
+	c.mu.Unlock()
+}
+
+func saveFingerprint(saveDir string) string {
+	entries, err := os.ReadDir(saveDir)
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", e.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String()
+}
+
+func verifySave(saveDir string) SaveCheck {
+	res := SaveCheck{Checked: time.Now()}
+	fail := func(format string, args ...any) SaveCheck {
+		res.Reason = fmt.Sprintf(format, args...)
+		return res
+	}
+	dat := filepath.Join(saveDir, "sav.dat")
+	info, err := os.Stat(dat)
+	if err != nil {
+		return fail("sav.dat missing")
+	}
+	if info.Size() < minSavSize {
+		return fail("sav.dat is only %d bytes", info.Size())
+	}
+	metaFile := findMetadataFile(saveDir)
+	if metaFile == "" {
+		return fail("metadata file missing")
+	}
+	if info, err := os.Stat(metaFile); err != nil || info.Size() < minMetadataSize {
+		return fail("%s is truncated", filepath.Base(metaFile))
+	}
+	if _, err := parseMetadata(saveDir); err != nil {
+		return fail("%s: %v", filepath.Base(metaFile), err)
+	}
+	sf, err := savfile.Check(dat)
+	if err != nil {
+		return fail("sav.dat: %v", err)
+	}
+	var compressed uint64
+	for _, c := range sf.Chunks {
+		compressed += uint64(c.CompressedSize)
+	}
+	if compressed > uint64(sf.Size) {
+		return fail("sav.dat chunk table claims %d bytes but file has %d", compressed, sf.Size)
+	}
+	res.OK = true
+	return res
+}
+
+// BrokenSaves verifies every save folder under profile and returns the ones
+// that failed.
+func (m *Manager) BrokenSaves(profile string) []SaveProblem {
+	problems, _ := m.checkSaves(profile)
+	return problems
+}
+
+// checkSaves is BrokenSaves that also counts the save folders it looked at.
+func (m *Manager) checkSaves(profile string) ([]SaveProblem, int) {
+	base := filepath.Join(m.profilesDir, SanitizeName(profile))
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return nil, 0
+	}
+	var res []SaveProblem
+	n := 0
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		n++
+		if c := m.integrity.check(filepath.Join(base, e.Name())); !c.OK {
+			res = append(res, SaveProblem{Name: e.Name(), Reason: c.Reason})
+		}
+	}
+	return res, n
+}
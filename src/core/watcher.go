@@ -0,0 +1,341 @@
+package core
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	watchDebounce     = 750 * time.Millisecond
+	watchPollInterval = 3 * time.Second
+)
+
+const (
+	saveCreated  = "created"
+	saveModified = "modified"
+	saveDeleted  = "deleted"
+)
+
+type SaveEvent struct {
+	Kind    string    `json:"kind"`
+	Profile string    `json:"profile"`
+	Save    string    `json:"save"`
+	Info    *SaveInfo `json:"info,omitempty"`
+}
+
+type indexedSave struct {
+	info        SaveInfo
+	mod         time.Time
+	fingerprint string
+}
+
+// saveWatcher keeps an in-memory index of the saves in the active profile and
+// the game save folder, updated from filesystem notifications or, where those
+// are unavailable, by polling.
+type saveWatcher struct {
+	m *Manager
+
+	mu      sync.RWMutex
+	index   map[string]map[string]indexedSave
+	polled  map[string]bool
+	subs    []func(SaveEvent)
+	scanMu  sync.Mutex
+	fsw     *fsnotify.Watcher
+	kick    chan struct{}
+	pending map[string]bool
+}
+
+func newSaveWatcher(m *Manager) *saveWatcher {
+	w := &saveWatcher{
+		m:       m,
+		index:   map[string]map[string]indexedSave{},
+		polled:  map[string]bool{},
+		kick:    make(chan struct{}, 1),
+		pending: map[string]bool{},
+	}
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("filesystem notifications unavailable, polling saves instead: %v", err)
+	} else {
+		w.fsw = fsw
+	}
+	return w
+}
+
+func (w *saveWatcher) subscribe(fn func(SaveEvent)) {
+	w.mu.Lock()
+	w.subs = append(w.subs, fn)
+	w.mu.Unlock()
+}
+
+// list returns the indexed saves of dir, newest first, and whether dir is
+// being watched at all.
+func (w *saveWatcher) list(dir string) ([]SaveInfo, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	saves, ok := w.index[watchKey(dir)]
+	if !ok {
+		return nil, false
+	}
+	entries := make([]indexedSave, 0, len(saves))
+	for _, e := range saves {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].mod.After(entries[j].mod) })
+	res := make([]SaveInfo, 0, len(entries))
+	for _, e := range entries {
+		res = append(res, e.info)
+	}
+	return res, true
+}
+
+// refresh rescans dir right away if it is watched; handlers call it after
+// changing a profile so the next listing reflects the change.
+func (w *saveWatcher) refresh(dir string) {
+	if w == nil {
+		return
+	}
+	key := watchKey(dir)
+	w.mu.RLock()
+	_, ok := w.index[key]
+	w.mu.RUnlock()
+	if ok {
+		w.rescan(key)
+	}
+}
+
+// rootsChanged asks the loop to re-resolve which folders to watch, e.g. after
+// a profile switch.
+func (w *saveWatcher) rootsChanged() {
+	if w == nil {
+		return
+	}
+	select {
+	case w.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (w *saveWatcher) run() {
+	var events chan fsnotify.Event
+	var errs chan error
+	if w.fsw != nil {
+		events, errs = w.fsw.Events, w.fsw.Errors
+	}
+	debounce := time.NewTimer(time.Hour)
+	debounce.Stop()
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	w.syncRoots()
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if root := w.rootOf(ev.Name); root != "" {
+				w.pending[root] = true
+				debounce.Reset(watchDebounce)
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			// Overflows and similar errors mean events were lost; rescan everything.
+			log.Printf("save watcher: %v", err)
+			w.mu.RLock()
+			for root := range w.index {
+				w.pending[root] = true
+			}
+			w.mu.RUnlock()
+			debounce.Reset(watchDebounce)
+		case <-debounce.C:
+			for root := range w.pending {
+				w.rescan(root)
+				delete(w.pending, root)
+			}
+		case <-ticker.C:
+			w.syncRoots()
+			w.mu.RLock()
+			var polled []string
+			for root := range w.polled {
+				polled = append(polled, root)
+			}
+			w.mu.RUnlock()
+			for _, root := range polled {
+				w.rescan(root)
+			}
+		case <-w.kick:
+			w.syncRoots()
+		}
+	}
+}
+
+// roots are the active profile folder and, when it is a real folder rather
+// than a link, the game save folder.
+func (w *saveWatcher) roots() []string {
+	var res []string
+	if active := w.m.ActiveProfile(); active != "" {
+		res = append(res, watchKey(filepath.Join(w.m.profilesDir, active)))
+	}
+	if w.m.gameSavePath != "" && !w.m.linker.IsLink(w.m.gameSavePath) && dirExists(w.m.gameSavePath) {
+		res = append(res, watchKey(w.m.gameSavePath))
+	}
+	return res
+}
+
+func (w *saveWatcher) syncRoots() {
+	want := map[string]bool{}
+	for _, r := range w.roots() {
+		want[r] = true
+	}
+	w.mu.RLock()
+	var stale, added []string
+	for root := range w.index {
+		if !want[root] {
+			stale = append(stale, root)
+		}
+	}
+	for root := range want {
+		if _, ok := w.index[root]; !ok {
+			added = append(added, root)
+		}
+	}
+	w.mu.RUnlock()
+	for _, root := range stale {
+		w.unwatch(root)
+	}
+	for _, root := range added {
+		w.watch(root)
+	}
+}
+
+func (w *saveWatcher) watch(root string) {
+	polled := w.fsw == nil
+	if !polled {
+		if err := w.fsw.Add(root); err != nil {
+			log.Printf("watching %s failed, polling instead: %v", root, err)
+			polled = true
+		}
+	}
+	w.mu.Lock()
+	w.index[root] = nil
+	w.polled[root] = polled
+	w.mu.Unlock()
+	w.rescan(root)
+}
+
+func (w *saveWatcher) unwatch(root string) {
+	w.mu.Lock()
+	saves := w.index[root]
+	delete(w.index, root)
+	delete(w.polled, root)
+	w.mu.Unlock()
+	delete(w.pending, root)
+	if w.fsw != nil {
+		_ = w.fsw.Remove(root)
+		for name := range saves {
+			_ = w.fsw.Remove(filepath.Join(root, name))
+		}
+	}
+}
+
+func (w *saveWatcher) rootOf(path string) string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	p := watchKey(path)
+	for root := range w.index {
+		if p == root || filepath.Dir(p) == root || filepath.Dir(filepath.Dir(p)) == root {
+			return root
+		}
+	}
+	return ""
+}
+
+func (w *saveWatcher) rescan(root string) {
+	w.scanMu.Lock()
+	defer w.scanMu.Unlock()
+	w.mu.RLock()
+	old, watched := w.index[root]
+	polled := w.polled[root]
+	first := old == nil
+	w.mu.RUnlock()
+	if !watched {
+		return
+	}
+	next := map[string]indexedSave{}
+	var events []SaveEvent
+	entries, _ := os.ReadDir(root)
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		savePath := filepath.Join(root, e.Name())
+		fp := saveFingerprint(savePath)
+		prev, existed := old[e.Name()]
+		if existed && prev.fingerprint == fp {
+			next[e.Name()] = prev
+			continue
+		}
+		info, mod, ok := w.m.readSave(root, e.Name())
+		if !ok {
+			continue
+		}
+		next[e.Name()] = indexedSave{info: info, mod: mod, fingerprint: fp}
+		if !existed && w.fsw != nil && !polled {
+			_ = w.fsw.Add(savePath)
+		}
+		kind := saveModified
+		if !existed {
+			kind = saveCreated
+		}
+		events = append(events, SaveEvent{Kind: kind, Save: e.Name(), Info: &info})
+	}
+	for name := range old {
+		if _, ok := next[name]; !ok {
+			events = append(events, SaveEvent{Kind: saveDeleted, Save: name})
+			w.m.integrity.forget(filepath.Join(root, name))
+		}
+	}
+	w.mu.Lock()
+	if _, ok := w.index[root]; ok {
+		w.index[root] = next
+	}
+	subs := append([]func(SaveEvent){}, w.subs...)
+	w.mu.Unlock()
+	if first {
+		return
+	}
+	profile := w.profileOf(root)
+	for _, ev := range events {
+		ev.Profile = profile
+		for _, fn := range subs {
+			fn(ev)
+		}
+	}
+}
+
+// profileOf returns the profile name for a watched folder, or "" for the
+// unmanaged game save folder.
+func (w *saveWatcher) profileOf(root string) string {
+	if samePath(filepath.Dir(root), w.m.profilesDir) {
+		return filepath.Base(root)
+	}
+	return ""
+}
+
+func watchKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
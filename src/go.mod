@@ -5,7 +5,9 @@ go 1.22.0
 replace golang.org/x/image => golang.org/x/image v0.24.0
 
 require (
+	github.com/fsnotify/fsnotify v1.8.0
 	github.com/getlantern/systray v1.2.2
+	github.com/klauspost/compress v1.18.0
 	github.com/sqweek/dialog v0.0.0-20240226140203-065105509627
 )
 
@@ -19,5 +21,5 @@ require (
 	github.com/getlantern/ops v0.0.0-20190325191751-d70cb0d6f85f // indirect
 	github.com/go-stack/stack v1.8.0 // indirect
 	github.com/oxtoacart/bpool v0.0.0-20190530202638-03653db5a59c // indirect
-	golang.org/x/sys v0.1.0 // indirect
+	golang.org/x/sys v0.13.0 // indirect
 )
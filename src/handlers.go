@@ -2,12 +2,13 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
-	"os"
-	"path/filepath"
-	"sort"
+	"strconv"
 	"strings"
-	"time"
+
+	"cybersaver/core"
 
 	"github.com/sqweek/dialog"
 )
@@ -31,25 +32,47 @@ func (s *server) handleState(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	profiles := s.listProfiles()
-	active := s.detectActiveProfile(profiles)
+	profiles := s.Profiles()
+	active := s.ActiveProfile()
 	path := ""
-	if s.gamePathExists && dirExists(s.gameSavePath) {
-		path = s.gameSavePath
+	if s.GamePathExists() {
+		path = s.GameSavePath()
 	}
 	writeJSON(w, map[string]any{
 		"profiles":    profiles,
 		"active":      active,
 		"gamePath":    path,
 		"pathMissing": path == "",
-		"profilesDir": s.profilesDir,
+		"profilesDir": s.ProfilesDir(),
+		"recovery":    s.Recovery(),
+		"backup":      s.BackupStatus(),
 	})
 }
 
+func (s *server) handleRecoverSwitch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Force bool `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	rec, err := s.RecoverSwitch(body.Force)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, rec)
+}
+
 func (s *server) handleProfiles(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		writeJSON(w, s.listProfiles())
+		writeJSON(w, s.Profiles())
 	case http.MethodPost:
 		var body struct {
 			Name string `json:"name"`
@@ -58,130 +81,181 @@ func (s *server) handleProfiles(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
 		}
-		name := sanitizeName(body.Name)
-		if name == "" {
-			http.Error(w, "name required", http.StatusBadRequest)
+		if _, err := s.CreateProfile(body.Name); err != nil {
+			httpError(w, err)
 			return
 		}
-		path := filepath.Join(s.profilesDir, name)
-		if _, err := os.Stat(path); err == nil {
-			http.Error(w, "profile exists", http.StatusConflict)
+		writeJSON(w, map[string]string{"status": "created"})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) handleProfileMeta(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		meta, err := s.ProfileMeta(r.URL.Query().Get("profile"))
+		if err != nil {
+			httpError(w, err)
 			return
 		}
-		if err := os.MkdirAll(path, 0o755); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeJSON(w, meta)
+	case http.MethodPost:
+		var body struct {
+			Profile string `json:"profile"`
+			core.ProfileMeta
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
 			return
 		}
-		writeJSON(w, map[string]string{"status": "created"})
+		meta, err := s.SetProfileMeta(body.Profile, body.ProfileMeta)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		writeJSON(w, meta)
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (s *server) handleProfileNote(w http.ResponseWriter, r *http.Request) {
+func (s *server) handlePrunePolicy(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		profile := sanitizeName(r.URL.Query().Get("profile"))
-		if profile == "" {
-			http.Error(w, "profile required", http.StatusBadRequest)
+		policy, err := s.PrunePolicy(r.URL.Query().Get("profile"))
+		if err != nil {
+			httpError(w, err)
 			return
 		}
-		writeJSON(w, profileNote{Profile: profile, Note: readNote(s.profilesDir, profile)})
+		writeJSON(w, policy)
 	case http.MethodPost:
-		var body profileNote
+		var body struct {
+			Profile string `json:"profile"`
+			core.PrunePolicy
+		}
 		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 			http.Error(w, "bad request", http.StatusBadRequest)
 			return
 		}
-		if body.Profile == "" {
-			http.Error(w, "profile required", http.StatusBadRequest)
-			return
-		}
-		if err := writeNote(s.profilesDir, body.Profile, body.Note); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		policy, err := s.SetPrunePolicy(body.Profile, body.PrunePolicy)
+		if err != nil {
+			httpError(w, err)
 			return
 		}
-		writeJSON(w, map[string]string{"status": "saved"})
+		writeJSON(w, policy)
 	default:
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (s *server) handleProfileDelete(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		http.NotFound(w, r)
-		return
-	}
-	name := strings.TrimPrefix(r.URL.Path, "/api/profiles/")
-	name = sanitizeName(name)
-	if name == "" {
-		http.Error(w, "invalid profile", http.StatusBadRequest)
+// handlePrune applies a profile's prune policy; dryRun only previews it.
+func (s *server) handlePrune(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	target := filepath.Join(s.profilesDir, name)
-	if target == "" {
-		http.NotFound(w, r)
-		return
+	var body struct {
+		Profile string `json:"profile"`
+		DryRun  bool   `json:"dryRun"`
 	}
-	if link, _ := os.Readlink(s.gameSavePath); samePath(link, target) {
-		http.Error(w, "profile is active, unload first", http.StatusConflict)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
-	if err := os.RemoveAll(target); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	res, err := s.Prune(body.Profile, body.DryRun)
+	if err != nil {
+		httpError(w, err)
 		return
 	}
-	writeJSON(w, map[string]string{"status": "deleted"})
+	writeJSON(w, res)
 }
 
-func (s *server) handleLoadProfile(w http.ResponseWriter, r *http.Request) {
+func (s *server) handleProfileRename(w http.ResponseWriter, r *http.Request) {
+	s.handleProfileCopy(w, r, s.renameProfile)
+}
+
+func (s *server) handleProfileClone(w http.ResponseWriter, r *http.Request) {
+	s.handleProfileCopy(w, r, s.CloneProfile)
+}
+
+// handleProfileCopy serves rename and clone, which take the same request.
+func (s *server) handleProfileCopy(w http.ResponseWriter, r *http.Request, op func(name, newName string) (string, error)) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if s.gameSavePath == "" {
-		http.Error(w, "game save path not set", http.StatusBadRequest)
-		return
-	}
-	if gameRunning.Load() {
-		http.Error(w, "cannot switch while Cyberpunk is running", http.StatusConflict)
-		return
-	}
 	var body struct {
-		Name string `json:"name"`
+		Profile string `json:"profile"`
+		Name    string `json:"name"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
-	name := sanitizeName(body.Name)
-	if name == "" {
-		http.Error(w, "invalid profile", http.StatusBadRequest)
+	name, err := op(body.Profile, body.Name)
+	if err != nil {
+		httpError(w, err)
 		return
 	}
-	target := filepath.Join(s.profilesDir, name)
-	if err := os.MkdirAll(target, 0o755); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	writeJSON(w, map[string]string{"status": "ok", "profile": name})
+}
+
+// renameProfile renames a profile and saves the backup schedule, which may
+// have named it.
+func (s *server) renameProfile(name, newName string) (string, error) {
+	newName, err := s.RenameProfile(name, newName)
+	if err != nil {
+		return "", err
+	}
+	cfg := loadConfig()
+	cfg.Backup = s.BackupConfig()
+	if err := saveConfig(cfg); err != nil {
+		log.Printf("backup config not saved after rename: %v", err)
+	}
+	return newName, nil
+}
+
+func (s *server) handleProfileDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.NotFound(w, r)
 		return
 	}
-	if err := switchJunction(s.gameSavePath, target); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	item, err := s.DeleteProfile(strings.TrimPrefix(r.URL.Path, "/api/profiles/"))
+	if err != nil {
+		httpError(w, err)
 		return
 	}
-	writeJSON(w, map[string]string{"status": "loaded", "profile": name})
+	writeJSON(w, map[string]any{"status": "deleted", "trash": item})
 }
 
-func (s *server) handleImport(w http.ResponseWriter, r *http.Request) {
+func (s *server) handleLoadProfile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if s.gameSavePath == "" {
-		http.Error(w, "game save path not set", http.StatusBadRequest)
+	var body struct {
+		Name  string `json:"name"`
+		Force bool   `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	problems, err := s.LoadProfile(body.Name, body.Force)
+	if err != nil {
+		httpError(w, err)
 		return
 	}
-	if gameRunning.Load() {
-		http.Error(w, "cannot import while Cyberpunk is running", http.StatusConflict)
+	if problems == nil {
+		problems = []core.SaveProblem{}
+	}
+	writeJSON(w, map[string]any{"status": "loaded", "profile": core.SanitizeName(body.Name), "warnings": problems})
+}
+
+func (s *server) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	var body struct {
@@ -191,16 +265,91 @@ func (s *server) handleImport(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
-	name := sanitizeName(body.Name)
-	if name == "" {
-		http.Error(w, "invalid profile", http.StatusBadRequest)
+	if err := s.ImportProfile(body.Name); err != nil {
+		httpError(w, err)
 		return
 	}
-	if err := s.importFromGamePath(name); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	writeJSON(w, map[string]string{"status": "imported"})
+}
+
+func (s *server) handleImportProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	writeJSON(w, map[string]string{"status": "imported"})
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "archive required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	if r.FormValue("verify") == "1" {
+		v, err := s.VerifyArchive(file, header.Size)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		writeJSON(w, v)
+		return
+	}
+	res, err := s.ImportArchive(file, header.Size, core.ImportOptions{
+		Profile:     r.FormValue("profile"),
+		ArchiveName: header.Filename,
+		Merge:       r.FormValue("merge") == "1",
+		Collision:   r.FormValue("collision"),
+	})
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, res)
+}
+
+func (s *server) handleSigningKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key, err := s.SigningKey()
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, key)
+}
+
+func (s *server) handleTrustedKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		keys, err := s.TrustedKeys()
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		writeJSON(w, keys)
+	case http.MethodPost:
+		var body struct {
+			KeyID string `json:"keyId"`
+			Name  string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := s.TrustKey(body.KeyID, body.Name); err != nil {
+			httpError(w, err)
+			return
+		}
+		writeJSON(w, map[string]string{"status": "trusted"})
+	case http.MethodDelete:
+		if err := s.DistrustKey(r.URL.Query().Get("keyId")); err != nil {
+			httpError(w, err)
+			return
+		}
+		writeJSON(w, map[string]string{"status": "deleted"})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
 }
 
 func (s *server) handleSaves(w http.ResponseWriter, r *http.Request) {
@@ -208,52 +357,182 @@ func (s *server) handleSaves(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	profile := sanitizeName(r.URL.Query().Get("profile"))
+	q := r.URL.Query()
+	profile := core.SanitizeName(q.Get("profile"))
 	if profile == "" {
 		http.Error(w, "profile required", http.StatusBadRequest)
 		return
 	}
-	base := filepath.Join(s.profilesDir, profile)
-	entries, err := os.ReadDir(base)
-	if err != nil {
-		writeJSON(w, []saveInfo{})
-		return
-	}
-	type saveWithTime struct {
-		saveInfo
-		mod time.Time
+	saves := s.Saves(profile)
+	if tags, favorite := q["tag"], q.Get("favorite") == "1"; len(tags) > 0 || favorite {
+		saves = filterSaves(saves, tags, favorite)
 	}
-	var saves []saveWithTime
-	for _, e := range entries {
-		if !e.IsDir() {
+	writeJSON(w, saves)
+}
+
+// filterSaves keeps the saves carrying every one of tags and, if favorite is
+// set, only starred ones.
+func filterSaves(saves []core.SaveInfo, tags []string, favorite bool) []core.SaveInfo {
+	res := []core.SaveInfo{}
+outer:
+	for _, sv := range saves {
+		if favorite && !sv.Favorite {
 			continue
 		}
-		info, err := e.Info()
+		for _, t := range tags {
+			if !sv.HasTag(t) {
+				continue outer
+			}
+		}
+		res = append(res, sv)
+	}
+	return res
+}
+
+func (s *server) handleSaveMeta(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		q := r.URL.Query()
+		writeJSON(w, s.SaveMeta(q.Get("profile"), q.Get("name")))
+	case http.MethodPost:
+		var body struct {
+			Profile string `json:"profile"`
+			Name    string `json:"name"`
+			core.SaveMetaUpdate
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		meta, err := s.UpdateSaveMeta(body.Profile, body.Name, body.SaveMetaUpdate)
 		if err != nil {
-			continue
+			httpError(w, err)
+			return
 		}
-		savePath := filepath.Join(base, e.Name())
-		ss := findScreenshot(savePath)
-		meta := readMetadata(savePath)
-		saves = append(saves, saveWithTime{
-			saveInfo: saveInfo{
-				Name:       e.Name(),
-				Modified:   info.ModTime().Format("2006-01-02 15:04:05"),
-				Type:       classifySave(e.Name()),
-				Screenshot: ss,
-				Playtime:   meta.Playtime,
-				Level:      meta.Level,
-				Quest:      meta.Quest,
-				QuestTitle: meta.QuestTitle,
-				Objective:  meta.Objective,
-			},
-			mod: info.ModTime(),
-		})
-	}
-	sort.Slice(saves, func(i, j int) bool { return saves[i].mod.After(saves[j].mod) })
-	resp := make([]saveInfo, 0, len(saves))
-	for _, s := range saves {
-		resp = append(resp, s.saveInfo)
+		writeJSON(w, meta)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) handleTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	profile := core.SanitizeName(r.URL.Query().Get("profile"))
+	if profile == "" {
+		http.Error(w, "profile required", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, s.Tags(profile))
+}
+
+func (s *server) handleSaveDetails(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	details, err := s.SaveDetails(r.URL.Query().Get("profile"), r.URL.Query().Get("name"))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, details)
+}
+
+// handleDiff compares two saves given as from and to, each "profile/save" or
+// a save name in profile.
+func (s *server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	from, to := core.ParseSaveRef(q.Get("from"), q.Get("profile")), core.ParseSaveRef(q.Get("to"), q.Get("profile"))
+	if from.Profile == "" || from.Name == "" || to.Profile == "" || to.Name == "" {
+		http.Error(w, "from and to saves required", http.StatusBadRequest)
+		return
+	}
+	diff, err := s.DiffSaves(from, to)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, diff)
+}
+
+func (s *server) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	tl, err := s.Timeline(r.URL.Query().Get("profile"))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, tl)
+}
+
+func (s *server) handleQuestProgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	progress, err := s.QuestProgress(r.URL.Query().Get("profile"))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, progress)
+}
+
+// handleSearch searches the saves of every profile; see core.Manager.Search
+// for the query syntax.
+func (s *server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	res, err := s.Search(q.Get("q"), limit)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, res)
+}
+
+func (s *server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	res, err := s.Verify(r.URL.Query().Get("profile"), r.URL.Query().Get("refresh") == "1")
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, res)
+}
+
+func (s *server) handleSaveInspect(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	profile := core.SanitizeName(r.URL.Query().Get("profile"))
+	name := core.SanitizeName(r.URL.Query().Get("name"))
+	file, err := s.InspectSave(profile, name)
+	if errors.Is(err, core.ErrInvalid) || errors.Is(err, core.ErrNotFound) {
+		httpError(w, err)
+		return
+	}
+	resp := map[string]any{"profile": profile, "name": name, "valid": err == nil, "file": file}
+	if err != nil {
+		resp["error"] = err.Error()
 	}
 	writeJSON(w, resp)
 }
@@ -271,18 +550,12 @@ func (s *server) handleDeleteSave(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
-	profile := sanitizeName(body.Profile)
-	name := sanitizeName(body.Name)
-	if profile == "" || name == "" {
-		http.Error(w, "invalid request", http.StatusBadRequest)
-		return
-	}
-	target := filepath.Join(s.profilesDir, profile, name)
-	if err := os.RemoveAll(target); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	item, err := s.DeleteSave(body.Profile, body.Name)
+	if err != nil {
+		httpError(w, err)
 		return
 	}
-	writeJSON(w, map[string]string{"status": "deleted"})
+	writeJSON(w, map[string]any{"status": "deleted", "trash": item})
 }
 
 func (s *server) handleCopySave(w http.ResponseWriter, r *http.Request) {
@@ -294,58 +567,134 @@ func (s *server) handleCopySave(w http.ResponseWriter, r *http.Request) {
 		Profile string `json:"profile"`
 		Name    string `json:"name"`
 		Target  string `json:"target"`
+		Force   bool   `json:"force"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
-	srcProfile := sanitizeName(body.Profile)
-	name := sanitizeName(body.Name)
-	targetProfile := sanitizeName(body.Target)
-	if srcProfile == "" || name == "" || targetProfile == "" {
-		http.Error(w, "missing fields", http.StatusBadRequest)
+	destPath, err := s.CopySave(body.Profile, body.Name, body.Target, body.Force)
+	if err != nil {
+		httpError(w, err)
 		return
 	}
-	srcPath := filepath.Join(s.profilesDir, srcProfile, name)
-	if _, err := os.Stat(srcPath); err != nil {
-		http.Error(w, "source save not found", http.StatusNotFound)
+	writeJSON(w, map[string]string{"status": "copied", "dest": destPath})
+}
+
+func (s *server) handleExportProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	destDir := filepath.Join(s.profilesDir, targetProfile)
-	if err := os.MkdirAll(destDir, 0o755); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	q := r.URL.Query()
+	profile := core.SanitizeName(q.Get("profile"))
+	if profile == "" {
+		http.Error(w, "profile required", http.StatusBadRequest)
+		return
+	}
+	if q.Get("check") == "1" {
+		writeJSON(w, map[string]any{"problems": s.BrokenSaves(profile)})
 		return
 	}
-	destPath := filepath.Join(destDir, name)
-	if _, err := os.Stat(destPath); err == nil {
-		destPath = filepath.Join(destDir, name+"_copy_"+time.Now().Format("20060102_150405"))
+	var saves []string
+	for _, v := range q["saves"] {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				saves = append(saves, name)
+			}
+		}
 	}
-	if err := copyDir(srcPath, destPath); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	exp, err := s.NewExport(profile, core.ExportOptions{
+		Format:           q.Get("format"),
+		Snapshot:         q.Get("snapshot"),
+		Saves:            saves,
+		SkipScreenshots:  q.Get("screenshots") == "0",
+		SkipNotes:        q.Get("notes") == "0",
+		IncludeSnapshots: q.Get("snapshots") == "1",
+		Force:            q.Get("force") == "1",
+		Sign:             q.Get("sign") == "1",
+		Signer:           q.Get("signer"),
+	})
+	if err != nil {
+		httpError(w, err)
 		return
 	}
-	writeJSON(w, map[string]string{"status": "copied", "dest": destPath})
+	w.Header().Set("Content-Type", exp.ContentType())
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+exp.Filename()+"\"")
+	if err := exp.Stream(w); err != nil {
+		// Headers are gone; all we can do is cut the download short.
+		log.Printf("export %s: %v", profile, err)
+		panic(http.ErrAbortHandler)
+	}
 }
 
-func (s *server) handleExportProfile(w http.ResponseWriter, r *http.Request) {
+// handleJob reports a recent job's progress and, for bulk jobs, the per-save
+// results so far.
+func (s *server) handleJob(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	profile := sanitizeName(r.URL.Query().Get("profile"))
-	if profile == "" {
-		http.Error(w, "profile required", http.StatusBadRequest)
+	st, err := s.Job(strings.TrimPrefix(r.URL.Path, "/api/jobs/"))
+	if err != nil {
+		httpError(w, err)
 		return
 	}
-	zipPath, err := createProfileZip(s.profilesDir, profile)
+	writeJSON(w, st)
+}
+
+// handleBulk starts a bulk operation in the background and answers 202 with
+// its job; clients poll /api/jobs/{id} for progress and per-save results. An
+// export streams one archive of the selected saves instead.
+func (s *server) handleBulk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		core.BulkRequest
+		Format string `json:"format"`
+		Sign   bool   `json:"sign"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if body.Op != core.BulkExport {
+		// The work goes on in the background; poll /api/jobs/{id} for it.
+		st, err := s.StartBulk(body.BulkRequest)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		writeJSONStatus(w, http.StatusAccepted, st)
+		return
+	}
+	saves, err := s.SelectSaves(body.Profile, body.Saves, body.Filter)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		httpError(w, err)
+		return
+	}
+	if len(saves) == 0 {
+		http.Error(w, "no saves selected", http.StatusBadRequest)
+		return
+	}
+	exp, err := s.NewExport(body.Profile, core.ExportOptions{
+		Format: body.Format,
+		Saves:  saves,
+		Force:  body.Force,
+		Sign:   body.Sign,
+	})
+	if err != nil {
+		httpError(w, err)
 		return
 	}
-	defer os.Remove(zipPath)
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", "attachment; filename=\""+profile+".zip\"")
-	http.ServeFile(w, r, zipPath)
+	w.Header().Set("Content-Type", exp.ContentType())
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+exp.Filename()+"\"")
+	if err := exp.Stream(w); err != nil {
+		log.Printf("bulk export %s: %v", body.Profile, err)
+		panic(http.ErrAbortHandler)
+	}
 }
 
 func (s *server) handleSelectPath(w http.ResponseWriter, r *http.Request) {
@@ -358,34 +707,238 @@ func (s *server) handleSelectPath(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "selection cancelled", http.StatusBadRequest)
 		return
 	}
-	s.gameSavePath = path
-	s.gamePathExists = dirExists(path)
+	s.SetGameSavePath(path)
 	writeJSON(w, map[string]string{"path": path})
 }
 
-func (s *server) listProfiles() []string {
-	entries, err := os.ReadDir(s.profilesDir)
+func (s *server) handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		profile := core.SanitizeName(r.URL.Query().Get("profile"))
+		if profile == "" {
+			http.Error(w, "profile required", http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, s.Snapshots(profile))
+	case http.MethodPost:
+		var body struct {
+			Profile string `json:"profile"`
+			Name    string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		snap, err := s.TakeSnapshot(body.Profile, body.Name)
+		if err != nil {
+			httpError(w, err)
+			return
+		}
+		writeJSON(w, snap)
+	case http.MethodDelete:
+		if err := s.DeleteSnapshot(r.URL.Query().Get("profile"), r.URL.Query().Get("id")); err != nil {
+			httpError(w, err)
+			return
+		}
+		writeJSON(w, map[string]string{"status": "deleted"})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) handleSnapshotDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	diff, err := s.DiffSnapshot(q.Get("profile"), q.Get("id"), q.Get("against"))
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, diff)
+}
+
+func (s *server) handleSnapshotRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Profile string `json:"profile"`
+		ID      string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	backup, err := s.RestoreSnapshot(body.Profile, body.ID)
 	if err != nil {
-		return []string{}
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, map[string]string{"status": "restored", "backup": backup})
+}
+
+func (s *server) handleSnapshotPin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		Profile string `json:"profile"`
+		ID      string `json:"id"`
+		Pinned  bool   `json:"pinned"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
 	}
-	var res []string
-	for _, e := range entries {
-		if e.IsDir() {
-			res = append(res, e.Name())
+	if err := s.PinSnapshot(body.Profile, body.ID, body.Pinned); err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, map[string]any{"status": "saved", "pinned": body.Pinned})
+}
+
+func (s *server) handleBackupConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.BackupConfig())
+	case http.MethodPost:
+		var body core.BackupConfig
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if err := s.SetBackupConfig(body); err != nil {
+			httpError(w, err)
+			return
+		}
+		cfg := loadConfig()
+		cfg.Backup = body
+		if err := saveConfig(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
+		writeJSON(w, map[string]string{"status": "saved"})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 	}
-	return res
 }
 
-func (s *server) detectActiveProfile(profiles []string) string {
-	target, err := os.Readlink(s.gameSavePath)
+func (s *server) handleTrash(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.Trash())
+}
+
+func (s *server) handleTrashRestore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		ID     string `json:"id"`
+		Target string `json:"target"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	item, err := s.RestoreTrash(body.ID, body.Target)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	writeJSON(w, item)
+}
+
+func (s *server) handleTrashPurge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var body struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	n, err := s.PurgeTrash(body.ID)
 	if err != nil {
-		return ""
+		httpError(w, err)
+		return
 	}
-	for _, p := range profiles {
-		if samePath(target, filepath.Join(s.profilesDir, p)) {
-			return p
+	writeJSON(w, map[string]int{"purged": n})
+}
+
+func (s *server) handleTrashConfig(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, map[string]int{"days": s.TrashDays()})
+	case http.MethodPost:
+		var body struct {
+			Days int `json:"days"`
 		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		cfg := loadConfig()
+		cfg.TrashDays = body.Days
+		if err := saveConfig(cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.SetTrashDays(body.Days)
+		writeJSON(w, map[string]int{"days": s.TrashDays()})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) handleStore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.StoreStats())
+}
+
+func (s *server) handleStoreGC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	st, err := s.CollectGarbage()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, st)
+}
+
+// httpError writes err with the status code its kind calls for.
+func httpError(w http.ResponseWriter, err error) {
+	var damaged *core.DamagedError
+	switch {
+	case errors.As(err, &damaged):
+		writeJSONStatus(w, http.StatusConflict, map[string]any{"error": damaged.Msg, "problems": damaged.Problems})
+	case errors.Is(err, core.ErrInvalid), errors.Is(err, core.ErrNoGamePath):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, core.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, core.ErrExists), errors.Is(err, core.ErrActive), errors.Is(err, core.ErrGameRunning), errors.Is(err, core.ErrPinned),
+		errors.Is(err, core.ErrSwitchPending):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, core.ErrUnreadable), errors.Is(err, core.ErrTampered):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
-	return ""
 }
@@ -6,8 +6,11 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
+	"cybersaver/core"
+
 	"github.com/getlantern/systray"
 )
 
@@ -17,40 +20,60 @@ const shutdownTimeout = 3 * time.Second
 const defaultPort = 8787
 
 func main() {
-	autoPath, ok := detectGameSavePath()
-	s := &server{
-		gameSavePath:   autoPath,
-		gamePathExists: ok,
-		profilesDir:    defaultProfilesDir(),
-	}
-
-	cfg := requirePort(loadConfig())
-	if cfg.GameSavePath != "" {
-		s.gameSavePath = cfg.GameSavePath
-		s.gamePathExists = dirExists(cfg.GameSavePath)
-	}
-	if cfg.ProfilesDir != "" {
-		s.profilesDir = cfg.ProfilesDir
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
 	}
-	if err := os.MkdirAll(s.profilesDir, 0o755); err != nil {
-		log.Fatalf("failed to create profiles dir: %v", err)
+	cfg := runSetupWizard(requirePort(loadConfig()))
+	s, err := newServer(cfg)
+	if err != nil {
+		log.Fatal(err)
 	}
-
-	cfg = runSetupWizard(cfg, s)
 	ensureProtection(s)
+	s.Start()
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", s.handleIndex)
-	mux.Handle("/files/", http.StripPrefix("/files/", http.FileServer(http.Dir(s.profilesDir))))
+	mux.Handle("/files/", http.StripPrefix("/files/", hideDotFiles(http.FileServer(http.Dir(s.ProfilesDir())))))
 	mux.HandleFunc("/api/state", s.handleState)
+	mux.HandleFunc("/api/recover_switch", s.handleRecoverSwitch)
+	mux.HandleFunc("/api/events", s.handleEvents)
 	mux.HandleFunc("/api/profiles", s.handleProfiles)
 	mux.HandleFunc("/api/profiles/", s.handleProfileDelete)
-	mux.HandleFunc("/api/profile_note", s.handleProfileNote)
+	mux.HandleFunc("/api/profile_meta", s.handleProfileMeta)
+	mux.HandleFunc("/api/prune_policy", s.handlePrunePolicy)
+	mux.HandleFunc("/api/prune", s.handlePrune)
+	mux.HandleFunc("/api/profile_rename", s.handleProfileRename)
+	mux.HandleFunc("/api/profile_clone", s.handleProfileClone)
 	mux.HandleFunc("/api/copy_save", s.handleCopySave)
 	mux.HandleFunc("/api/export_profile", s.handleExportProfile)
+	mux.HandleFunc("/api/signing_key", s.handleSigningKey)
+	mux.HandleFunc("/api/trusted_keys", s.handleTrustedKeys)
 	mux.HandleFunc("/api/load", s.handleLoadProfile)
 	mux.HandleFunc("/api/import", s.handleImport)
+	mux.HandleFunc("/api/import_profile", s.handleImportProfile)
 	mux.HandleFunc("/api/saves", s.handleSaves)
+	mux.HandleFunc("/api/save_details", s.handleSaveDetails)
+	mux.HandleFunc("/api/diff", s.handleDiff)
+	mux.HandleFunc("/api/timeline", s.handleTimeline)
+	mux.HandleFunc("/api/quests", s.handleQuestProgress)
+	mux.HandleFunc("/api/search", s.handleSearch)
+	mux.HandleFunc("/api/save_inspect", s.handleSaveInspect)
+	mux.HandleFunc("/api/save_meta", s.handleSaveMeta)
+	mux.HandleFunc("/api/tags", s.handleTags)
+	mux.HandleFunc("/api/bulk", s.handleBulk)
+	mux.HandleFunc("/api/jobs/", s.handleJob)
+	mux.HandleFunc("/api/verify", s.handleVerify)
+	mux.HandleFunc("/api/snapshots", s.handleSnapshots)
+	mux.HandleFunc("/api/snapshots/diff", s.handleSnapshotDiff)
+	mux.HandleFunc("/api/snapshots/restore", s.handleSnapshotRestore)
+	mux.HandleFunc("/api/snapshots/pin", s.handleSnapshotPin)
+	mux.HandleFunc("/api/backup_config", s.handleBackupConfig)
+	mux.HandleFunc("/api/trash", s.handleTrash)
+	mux.HandleFunc("/api/trash/restore", s.handleTrashRestore)
+	mux.HandleFunc("/api/trash/purge", s.handleTrashPurge)
+	mux.HandleFunc("/api/trash/config", s.handleTrashConfig)
+	mux.HandleFunc("/api/store", s.handleStore)
+	mux.HandleFunc("/api/store/gc", s.handleStoreGC)
 	mux.HandleFunc("/api/delete_save", s.handleDeleteSave)
 	mux.HandleFunc("/api/select_path", s.handleSelectPath)
 
@@ -94,14 +117,48 @@ func main() {
 				systray.SetIcon(iconBytes())
 				systray.SetTooltip("Cyberpunk 2077 Save Profiles")
 			}
+			s.SetGameRunning(running)
 		})
 	}, func() {
 		shutdownServer(httpServer)
 	})
 }
 
+// newServer opens the profiles folder named in cfg, finishing any profile
+// switch that was interrupted.
+func newServer(cfg appConfig) (*server, error) {
+	profilesDir := cfg.ProfilesDir
+	if profilesDir == "" {
+		profilesDir = defaultProfilesDir()
+	}
+	m, err := core.New(core.Options{
+		GameSavePath: cfg.GameSavePath,
+		ProfilesDir:  profilesDir,
+		Backup:       cfg.Backup,
+		TrashDays:    cfg.TrashDays,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &server{Manager: m}, nil
+}
+
 func shutdownServer(s *http.Server) {
 	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 	_ = s.Shutdown(ctx)
 }
+
+// hideDotFiles answers 404 for any path with a segment starting with a dot,
+// keeping the trash, store, snapshots and other bookkeeping out of /files/.
+func hideDotFiles(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, seg := range strings.Split(r.URL.Path, "/") {
+			if strings.HasPrefix(seg, ".") {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
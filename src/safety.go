@@ -4,8 +4,6 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
-	"time"
 
 	"github.com/sqweek/dialog"
 )
@@ -13,7 +11,7 @@ import (
 // ensureProtection prompts the user about junction usage and optionally backs up saves.
 // It writes a marker file in the profiles directory so we only prompt once.
 func ensureProtection(s *server) {
-	marker := filepath.Join(s.profilesDir, ".warning_ack")
+	marker := filepath.Join(s.ProfilesDir(), ".warning_ack")
 	if _, err := os.Stat(marker); err == nil {
 		return
 	}
@@ -26,22 +24,17 @@ func ensureProtection(s *server) {
 	}
 
 	// Warn if an existing junction points somewhere else.
-	if info, err := os.Lstat(s.gameSavePath); err == nil && info.Mode()&os.ModeSymlink != 0 {
-		if target, err := os.Readlink(s.gameSavePath); err == nil {
-			if !pointsIntoProfiles(target, s.profilesDir) {
-				msg := "An existing junction/symlink is already at the game save location and points to:\n" + target + "\n\nCyberSaver will replace it and point to its profiles directory. Continue?"
-				replace := dialog.Message(msg).Title("Replace Existing Junction").YesNo()
-				if !replace {
-					log.Printf("User cancelled at replace-junction prompt; exiting.")
-					os.Exit(0)
-				}
-			}
+	if target := s.ForeignLink(); target != "" {
+		msg := "An existing junction/symlink is already at the game save location and points to:\n" + target + "\n\nCyberSaver will replace it and point to its profiles directory. Continue?"
+		replace := dialog.Message(msg).Title("Replace Existing Junction").YesNo()
+		if !replace {
+			log.Printf("User cancelled at replace-junction prompt; exiting.")
+			os.Exit(0)
 		}
 	}
 
-	if s.gamePathExists && dirExists(s.gameSavePath) {
-		backupDir := filepath.Join(filepath.Dir(s.gameSavePath), "Cyberpunk 2077_backup_"+time.Now().Format("20060102_150405"))
-		if err := copyDir(s.gameSavePath, backupDir); err != nil {
+	if s.GamePathExists() {
+		if backupDir, err := s.BackupGameSaves(); err != nil {
 			log.Printf("Backup failed: %v", err)
 		} else {
 			log.Printf("Backup created at %s", backupDir)
@@ -50,14 +43,3 @@ func ensureProtection(s *server) {
 
 	_ = os.WriteFile(marker, []byte("ack"), 0o644)
 }
-
-func pointsIntoProfiles(target, profilesDir string) bool {
-	t, err1 := filepath.Abs(target)
-	p, err2 := filepath.Abs(profilesDir)
-	if err1 != nil || err2 != nil {
-		return false
-	}
-	t = strings.ToLower(filepath.Clean(t))
-	p = strings.ToLower(filepath.Clean(p))
-	return strings.HasPrefix(t, p)
-}
@@ -0,0 +1,358 @@
+// Package savfile reads the structure of Cyberpunk 2077 sav.dat files: the CSAV
+// header, the compression chunk table and the trailing node table. Chunk payloads
+// are never decompressed, so inspecting even a large save is cheap.
+package savfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf16"
+)
+
+const (
+	maxChunks    = 1 << 16
+	maxNodes     = 1 << 20
+	maxStringLen = 1 << 12
+)
+
+var (
+	magicSave   = [2]string{"CSAV", "VASC"}
+	magicChunks = [2]string{"FZLC", "CLZF"}
+	magicNodes  = [2]string{"NODE", "EDON"}
+	magicDone   = [2]string{"DONE", "ENOD"}
+	magicLZ4    = [2]string{"XLZ4", "4ZLX"}
+)
+
+var ErrNotSave = errors.New("not a Cyberpunk 2077 save (missing CSAV magic)")
+
+type Header struct {
+	SaveVersion    uint32 `json:"saveVersion"`
+	GameVersion    uint32 `json:"gameVersion"`
+	GameDefinition string `json:"gameDefinition"`
+	Timestamp      uint64 `json:"timestamp,omitempty"`
+	ArchiveVersion uint32 `json:"archiveVersion,omitempty"`
+}
+
+type Chunk struct {
+	Offset           uint32 `json:"offset"`
+	CompressedSize   uint32 `json:"compressedSize"`
+	DecompressedSize uint32 `json:"decompressedSize"`
+}
+
+type Node struct {
+	Index   int    `json:"index"`
+	Name    string `json:"name"`
+	NextID  int32  `json:"nextId"`
+	ChildID int32  `json:"childId"`
+	Offset  uint32 `json:"offset"`
+	Size    uint32 `json:"size"`
+}
+
+type File struct {
+	Size            int64   `json:"size"`
+	Header          Header  `json:"header"`
+	Chunks          []Chunk `json:"chunks"`
+	DataSize        uint64  `json:"dataSize"`
+	NodeTableOffset uint32  `json:"nodeTableOffset"`
+	Nodes           []Node  `json:"nodes"`
+}
+
+// Open parses the sav.dat at path. The returned File does not keep the file open.
+func Open(path string) (*File, error) {
+	return readFile(path, Read)
+}
+
+// readFile opens path and hands it to parse.
+func readFile(path string, parse func(io.ReaderAt, int64) (*File, error)) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return parse(f, info.Size())
+}
+
+// Read parses the header, chunk table and node table from r.
+func Read(r io.ReaderAt, size int64) (*File, error) {
+	if size < 16 {
+		return nil, fmt.Errorf("file too small (%d bytes)", size)
+	}
+	sf := &File{Size: size}
+	br := &reader{r: r, size: size}
+	if !br.magic(magicSave) {
+		if br.err != nil {
+			return nil, br.err
+		}
+		return nil, ErrNotSave
+	}
+	if err := sf.readHeader(br); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	if err := sf.readChunks(br); err != nil {
+		return nil, fmt.Errorf("chunk table: %w", err)
+	}
+	if err := sf.readNodes(r); err != nil {
+		return nil, fmt.Errorf("node table: %w", err)
+	}
+	return sf, nil
+}
+
+func (sf *File) readHeader(br *reader) error {
+	sf.Header.SaveVersion = br.u32()
+	sf.Header.GameVersion = br.u32()
+	sf.Header.GameDefinition = br.str()
+	if br.err != nil {
+		return br.err
+	}
+	// The fields between the game definition and the chunk table changed across
+	// patches, so use the distance to the chunk magic to tell which are present.
+	start := br.pos
+	for gap := int64(0); gap <= 16; gap += 4 {
+		br.pos = start + gap
+		if !br.magic(magicChunks) {
+			continue
+		}
+		br.pos = start
+		switch gap {
+		case 12:
+			sf.Header.Timestamp = br.u64()
+			sf.Header.ArchiveVersion = br.u32()
+		case 4:
+			sf.Header.ArchiveVersion = br.u32()
+		default:
+			br.pos += gap
+		}
+		return br.err
+	}
+	if br.err != nil {
+		return br.err
+	}
+	return errors.New("chunk table not found after header")
+}
+
+func (sf *File) readChunks(br *reader) error {
+	if !br.magic(magicChunks) {
+		return errors.New("missing FZLC magic")
+	}
+	count := br.u32()
+	if br.err != nil {
+		return br.err
+	}
+	if count == 0 || count > maxChunks {
+		return fmt.Errorf("implausible chunk count %d", count)
+	}
+	sf.Chunks = make([]Chunk, 0, count)
+	for i := uint32(0); i < count; i++ {
+		c := Chunk{Offset: br.u32(), CompressedSize: br.u32(), DecompressedSize: br.u32()}
+		if br.err != nil {
+			return br.err
+		}
+		sf.Chunks = append(sf.Chunks, c)
+		sf.DataSize += uint64(c.DecompressedSize)
+	}
+	return nil
+}
+
+func (sf *File) readNodes(r io.ReaderAt) error {
+	br := &reader{r: r, size: sf.Size, pos: sf.Size - 8}
+	sf.NodeTableOffset = br.u32()
+	if !br.magic(magicDone) {
+		if br.err != nil {
+			return br.err
+		}
+		return errors.New("missing DONE trailer")
+	}
+	if int64(sf.NodeTableOffset) >= sf.Size-8 {
+		return fmt.Errorf("offset %d beyond end of file", sf.NodeTableOffset)
+	}
+	br.pos = int64(sf.NodeTableOffset)
+	if !br.magic(magicNodes) {
+		if br.err != nil {
+			return br.err
+		}
+		return errors.New("missing NODE magic")
+	}
+	count := br.packed()
+	if br.err != nil {
+		return br.err
+	}
+	if count < 0 || count > maxNodes {
+		return fmt.Errorf("implausible node count %d", count)
+	}
+	sf.Nodes = make([]Node, 0, count)
+	for i := 0; i < int(count); i++ {
+		n := Node{Index: i, Name: br.str(), NextID: int32(br.u32()), ChildID: int32(br.u32()), Offset: br.u32(), Size: br.u32()}
+		if br.err != nil {
+			return fmt.Errorf("node %d: %w", i, br.err)
+		}
+		sf.Nodes = append(sf.Nodes, n)
+	}
+	return nil
+}
+
+// Check parses and validates the sav.dat at path.
+func Check(path string) (*File, error) {
+	return readFile(path, ReadChecked)
+}
+
+// ReadChecked parses r like Read and validates the result.
+func ReadChecked(r io.ReaderAt, size int64) (*File, error) {
+	sf, err := Read(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return sf, sf.Validate(r)
+}
+
+// Validate checks that the parsed tables are consistent with each other and with
+// the file size, reading only the chunk markers from r.
+func (sf *File) Validate(r io.ReaderAt) error {
+	if len(sf.Nodes) == 0 {
+		return errors.New("node table is empty")
+	}
+	end := int64(sf.NodeTableOffset)
+	for i, c := range sf.Chunks {
+		if c.CompressedSize == 0 || c.DecompressedSize == 0 {
+			return fmt.Errorf("chunk %d is empty", i)
+		}
+		if int64(c.Offset)+int64(c.CompressedSize) > end {
+			return fmt.Errorf("chunk %d runs past the node table (file truncated?)", i)
+		}
+		br := &reader{r: r, size: sf.Size, pos: int64(c.Offset)}
+		if !br.magic(magicLZ4) {
+			return fmt.Errorf("chunk %d has no XLZ4 marker", i)
+		}
+	}
+	limit := sf.DataSize
+	if len(sf.Chunks) > 0 {
+		// Node offsets count from the start of the file, and the uncompressed
+		// prefix before the first chunk is part of that space.
+		limit += uint64(sf.Chunks[0].Offset)
+	}
+	count := int32(len(sf.Nodes))
+	for _, n := range sf.Nodes {
+		if n.Name == "" {
+			return fmt.Errorf("node %d has no name", n.Index)
+		}
+		if n.NextID < -1 || n.NextID >= count || n.ChildID < -1 || n.ChildID >= count {
+			return fmt.Errorf("node %d (%s) links outside the table", n.Index, n.Name)
+		}
+		if uint64(n.Offset)+uint64(n.Size) > limit {
+			return fmt.Errorf("node %d (%s) extends past the save data", n.Index, n.Name)
+		}
+	}
+	return nil
+}
+
+type reader struct {
+	r    io.ReaderAt
+	size int64
+	pos  int64
+	err  error
+}
+
+func (br *reader) read(n int) []byte {
+	if br.err != nil {
+		return nil
+	}
+	if br.pos < 0 || br.pos+int64(n) > br.size {
+		br.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	buf := make([]byte, n)
+	if _, err := br.r.ReadAt(buf, br.pos); err != nil {
+		br.err = err
+		return nil
+	}
+	br.pos += int64(n)
+	return buf
+}
+
+func (br *reader) magic(want [2]string) bool {
+	b := br.read(4)
+	if b == nil {
+		return false
+	}
+	return bytes.Equal(b, []byte(want[0])) || bytes.Equal(b, []byte(want[1]))
+}
+
+func (br *reader) u32() uint32 {
+	b := br.read(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (br *reader) u64() uint64 {
+	b := br.read(8)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// packed reads the engine's variable-length integer: the first byte carries a
+// sign bit, a continuation bit and six value bits; later bytes carry seven.
+func (br *reader) packed() int64 {
+	b := br.read(1)
+	if b == nil {
+		return 0
+	}
+	neg := b[0]&0x80 != 0
+	val := int64(b[0] & 0x3f)
+	more := b[0]&0x40 != 0
+	for shift := 6; more; shift += 7 {
+		if shift > 35 {
+			br.err = errors.New("packed integer too long")
+			return 0
+		}
+		b = br.read(1)
+		if b == nil {
+			return 0
+		}
+		val |= int64(b[0]&0x7f) << shift
+		more = b[0]&0x80 != 0
+	}
+	if neg {
+		return -val
+	}
+	return val
+}
+
+// str reads a length-prefixed string. A negative length means single-byte
+// characters, a positive one UTF-16.
+func (br *reader) str() string {
+	n := br.packed()
+	if br.err != nil {
+		return ""
+	}
+	if n < 0 {
+		if -n > maxStringLen {
+			br.err = fmt.Errorf("string length %d too large", -n)
+			return ""
+		}
+		return string(br.read(int(-n)))
+	}
+	if n > maxStringLen {
+		br.err = fmt.Errorf("string length %d too large", n)
+		return ""
+	}
+	b := br.read(int(n) * 2)
+	if b == nil {
+		return ""
+	}
+	u := make([]uint16, n)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[i*2:])
+	}
+	return string(utf16.Decode(u))
+}
@@ -0,0 +1,194 @@
+package savfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testNode struct {
+	name         string
+	next, child  int32
+	offset, size uint32
+}
+
+// testSave describes a synthetic sav.dat: a header with an archive version,
+// one chunk table, the chunks themselves and a node table.
+type testSave struct {
+	chunks []Chunk
+	nodes  []testNode
+	// chunkOffset, when set, replaces the offset of every chunk in the table.
+	chunkOffset uint32
+	// nodeTableOffset, when set, replaces the offset in the trailer.
+	nodeTableOffset uint32
+}
+
+func validSave() testSave {
+	return testSave{
+		chunks: []Chunk{{CompressedSize: 8, DecompressedSize: 100}, {CompressedSize: 12, DecompressedSize: 200}},
+		nodes: []testNode{
+			{name: "inventory", next: 1, child: -1, offset: 40, size: 60},
+			{name: "PlayerDevelopmentData", next: -1, child: -1, offset: 100, size: 150},
+		},
+	}
+}
+
+func putStr(b *bytes.Buffer, s string) {
+	// Short single-byte strings: sign bit set, length in the low six bits.
+	b.WriteByte(0x80 | byte(len(s)))
+	b.WriteString(s)
+}
+
+func putU32(b *bytes.Buffer, v uint32) {
+	binary.Write(b, binary.LittleEndian, v)
+}
+
+func (ts testSave) bytes() []byte {
+	var b bytes.Buffer
+	b.WriteString("CSAV")
+	putU32(&b, 195)
+	putU32(&b, 2100)
+	putStr(&b, "gamedef")
+	putU32(&b, 1) // archive version
+	b.WriteString("FZLC")
+	putU32(&b, uint32(len(ts.chunks)))
+	tableEnd := b.Len() + 12*len(ts.chunks)
+	off := uint32(tableEnd)
+	for _, c := range ts.chunks {
+		c.Offset = off
+		if ts.chunkOffset != 0 {
+			c.Offset = ts.chunkOffset
+		}
+		putU32(&b, c.Offset)
+		putU32(&b, c.CompressedSize)
+		putU32(&b, c.DecompressedSize)
+		off += c.CompressedSize
+	}
+	for _, c := range ts.chunks {
+		b.WriteString("XLZ4")
+		b.Write(make([]byte, c.CompressedSize-4))
+	}
+	nodeTable := uint32(b.Len())
+	b.WriteString("NODE")
+	b.WriteByte(byte(len(ts.nodes)))
+	for _, n := range ts.nodes {
+		putStr(&b, n.name)
+		putU32(&b, uint32(n.next))
+		putU32(&b, uint32(n.child))
+		putU32(&b, n.offset)
+		putU32(&b, n.size)
+	}
+	if ts.nodeTableOffset != 0 {
+		nodeTable = ts.nodeTableOffset
+	}
+	putU32(&b, nodeTable)
+	b.WriteString("DONE")
+	return b.Bytes()
+}
+
+func TestReadValid(t *testing.T) {
+	data := validSave().bytes()
+	sf, err := ReadChecked(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sf.Header.SaveVersion != 195 || sf.Header.GameVersion != 2100 || sf.Header.GameDefinition != "gamedef" || sf.Header.ArchiveVersion != 1 {
+		t.Errorf("header = %+v", sf.Header)
+	}
+	if len(sf.Chunks) != 2 || sf.DataSize != 300 {
+		t.Errorf("chunks = %+v, data size %d", sf.Chunks, sf.DataSize)
+	}
+	if len(sf.Nodes) != 2 || sf.Nodes[1].Name != "PlayerDevelopmentData" || sf.Nodes[1].Size != 150 {
+		t.Errorf("nodes = %+v", sf.Nodes)
+	}
+}
+
+func TestReadCheckedErrors(t *testing.T) {
+	valid := validSave().bytes()
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"too small", valid[:12], "too small"},
+		{"truncated header", valid[:20], "header"},
+		{"bad magic", append([]byte("RIFF"), valid[4:]...), ErrNotSave.Error()},
+		{"chunk offset past node table", func() []byte {
+			ts := validSave()
+			ts.chunkOffset = 1 << 20
+			return ts.bytes()
+		}(), "runs past the node table"},
+		{"chunk offset off its marker", func() []byte {
+			ts := validSave()
+			ts.chunkOffset = 8
+			return ts.bytes()
+		}(), "no XLZ4 marker"},
+		{"node table offset past end", func() []byte {
+			ts := validSave()
+			ts.nodeTableOffset = 1 << 20
+			return ts.bytes()
+		}(), "beyond end of file"},
+		{"node table offset off its magic", func() []byte {
+			ts := validSave()
+			ts.nodeTableOffset = 4
+			return ts.bytes()
+		}(), "missing NODE magic"},
+		{"node link outside table", func() []byte {
+			ts := validSave()
+			ts.nodes[0].child = 7
+			return ts.bytes()
+		}(), "links outside the table"},
+		{"node past save data", func() []byte {
+			ts := validSave()
+			ts.nodes[1].size = 1 << 20
+			return ts.bytes()
+		}(), "extends past the save data"},
+		{"empty node table", func() []byte {
+			ts := validSave()
+			ts.nodes = nil
+			return ts.bytes()
+		}(), "node table is empty"},
+		{"node table cut short", func() []byte {
+			// Drop the last node's fields but keep the trailer.
+			data := validSave().bytes()
+			trailer := append([]byte{}, data[len(data)-8:]...)
+			return append(data[:len(data)-8-10], trailer...)
+		}(), "node 1"},
+		{"missing trailer", valid[:len(valid)-4], "missing DONE trailer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ReadChecked(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBadMagicIsErrNotSave(t *testing.T) {
+	data := append([]byte("RIFF"), validSave().bytes()[4:]...)
+	if _, err := Read(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrNotSave) {
+		t.Fatalf("err = %v, want ErrNotSave", err)
+	}
+}
+
+func TestOpenAndCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sav.dat")
+	if err := os.WriteFile(path, validSave().bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if _, err := Check(path); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if _, err := Check(filepath.Join(t.TempDir(), "missing.dat")); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("Check of a missing file: %v", err)
+	}
+}
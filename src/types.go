@@ -1,32 +1,8 @@
 package main
 
-type server struct {
-	gameSavePath   string
-	gamePathExists bool
-	profilesDir    string
-}
-
-type saveInfo struct {
-	Name       string `json:"name"`
-	Modified   string `json:"modified"`
-	Type       string `json:"type"`
-	Screenshot string `json:"screenshot"`
-	Playtime   string `json:"playtime"`
-	Level      string `json:"level"`
-	Quest      string `json:"quest"`
-	QuestTitle string `json:"questTitle"`
-	Objective  string `json:"objective"`
-}
+import "cybersaver/core"
 
-type metaSummary struct {
-	Playtime   string
-	Level      string
-	Quest      string
-	QuestTitle string
-	Objective  string
-}
-
-type profileNote struct {
-	Profile string `json:"profile"`
-	Note    string `json:"note"`
+// server adapts a core.Manager to the HTTP API and the command line.
+type server struct {
+	*core.Manager
 }